@@ -0,0 +1,229 @@
+package grep
+
+// ApproxFinder finds lines containing the pattern with at most maxErrors
+// insertions, deletions or substitutions. Patterns up to 64 bytes are matched
+// with the bit-parallel algorithm of Myers:
+// https://doi.org/10.1145/316542.316550
+// Longer patterns fall back to the dynamic programming algorithm of Sellers.
+type ApproxFinder struct {
+	pattern   []byte
+	reversed  []byte
+	maxErrors int
+
+	// peq and reversedPeq are the Myers tables for pattern and reversed,
+	// shared by the scorers of all goroutines.
+	peq         *[256]uint64
+	reversedPeq *[256]uint64
+
+	*finder
+}
+
+// MakeApproxFinder builds a finder for pattern allowing maxErrors edits. The
+// edit distance of each match is reported in Span.Distance.
+func MakeApproxFinder(pattern string, maxErrors int) *ApproxFinder {
+	if maxErrors < 0 {
+		maxErrors = 0
+	}
+	f := &ApproxFinder{
+		pattern:   []byte(pattern),
+		reversed:  reverse([]byte(pattern)),
+		maxErrors: maxErrors,
+	}
+	if len(pattern) <= 64 {
+		f.peq = makePeq(f.pattern)
+		f.reversedPeq = makePeq(f.reversed)
+	}
+	f.finder = makeFinder(f)
+	return f
+}
+
+func (f *ApproxFinder) matchLine(line []byte, res *Line) bool {
+	res.Spans = f.FindAll(line, f.overlapping)
+	return len(res.Spans) > 0
+}
+
+// FindAll returns the spans of approximate matches in text. Among the ends of
+// a match the one with the lowest distance is taken, and the match starts
+// where the pattern aligns with the fewest edits. With overlapping set the
+// next match is looked for from the byte after the start of the previous one.
+func (f *ApproxFinder) FindAll(text []byte, overlapping bool) []Span {
+	if len(f.pattern) == 0 {
+		return nil
+	}
+	forward := makeEditScorer(f.pattern, f.peq, false)
+	backward := makeEditScorer(f.reversed, f.reversedPeq, true)
+
+	var spans []Span
+	for pos := 0; pos < len(text); {
+		end, distance := f.matchEnd(forward, text, pos)
+		if end == -1 {
+			break
+		}
+		start := f.matchStart(backward, text[pos:end], distance) + pos
+		spans = append(spans, Span{Start: start, End: end, Distance: distance})
+		if overlapping || end == start {
+			pos = start + 1
+		} else {
+			pos = end
+		}
+	}
+	return spans
+}
+
+// matchEnd returns the end of the first match in text[pos:] and its
+// distance, or -1 if there is none.
+func (f *ApproxFinder) matchEnd(scorer editScorer, text []byte, pos int) (int, int) {
+	scorer.reset()
+	end, distance := -1, 0
+	for j := pos; j < len(text); j++ {
+		score := scorer.step(text[j])
+		if score <= f.maxErrors && (end == -1 || score < distance) {
+			end, distance = j+1, score
+		} else if end != -1 {
+			break
+		}
+	}
+	return end, distance
+}
+
+// matchStart scans text backwards from its end and returns the start of the
+// shortest suffix that is within distance of the pattern.
+func (f *ApproxFinder) matchStart(scorer editScorer, text []byte, distance int) int {
+	if len(f.pattern) <= distance {
+		return len(text)
+	}
+	scorer.reset()
+	for i := len(text) - 1; i >= 0; i-- {
+		if scorer.step(text[i]) <= distance {
+			return i
+		}
+	}
+	return 0
+}
+
+// editScorer computes the edit distance between the pattern and the text read
+// so far, one byte at a time. Unless anchored, the text may skip any prefix
+// for free, so the distance is the best over all substrings ending at the
+// last byte read.
+type editScorer interface {
+	reset()
+	step(c byte) int
+}
+
+// makeEditScorer returns a Myers scorer if the pattern table peq is given and
+// a Sellers scorer otherwise.
+func makeEditScorer(pattern []byte, peq *[256]uint64, anchored bool) editScorer {
+	if peq != nil {
+		last := uint64(1) << (len(pattern) - 1)
+		return &myersScorer{
+			peq:      peq,
+			last:     last,
+			mask:     last | (last - 1),
+			length:   len(pattern),
+			anchored: anchored,
+		}
+	}
+	return &sellersScorer{
+		pattern:  pattern,
+		column:   make([]int, len(pattern)+1),
+		anchored: anchored,
+	}
+}
+
+type myersScorer struct {
+	// peq[c] has bit i set when pattern[i] == c.
+	peq *[256]uint64
+
+	// pv and mv hold the positive and negative vertical deltas of the
+	// current column of the dynamic programming matrix.
+	pv, mv uint64
+
+	mask, last uint64
+	length     int
+	score      int
+	anchored   bool
+}
+
+func makePeq(pattern []byte) *[256]uint64 {
+	var peq [256]uint64
+	for i, c := range pattern {
+		peq[c] |= 1 << i
+	}
+	return &peq
+}
+
+func (s *myersScorer) reset() {
+	s.pv = s.mask
+	s.mv = 0
+	s.score = s.length
+}
+
+func (s *myersScorer) step(c byte) int {
+	eq := s.peq[c]
+	xv := eq | s.mv
+	xh := (((eq & s.pv) + s.pv) ^ s.pv) | eq
+	ph := s.mv | ^(xh | s.pv)
+	mh := s.pv & xh
+	if ph&s.last != 0 {
+		s.score++
+	} else if mh&s.last != 0 {
+		s.score--
+	}
+	ph <<= 1
+	mh <<= 1
+	if s.anchored {
+		// The first row grows by one per text byte instead of staying zero.
+		ph |= 1
+	}
+	s.pv = (mh | ^(xv | ph)) & s.mask
+	s.mv = ph & xv
+	return s.score
+}
+
+type sellersScorer struct {
+	pattern []byte
+	// column[i] is the distance of pattern[:i] against the text read so far.
+	column   []int
+	read     int
+	anchored bool
+}
+
+func (s *sellersScorer) reset() {
+	for i := range s.column {
+		s.column[i] = i
+	}
+	s.read = 0
+}
+
+func (s *sellersScorer) step(c byte) int {
+	s.read++
+	diagonal := s.column[0]
+	if s.anchored {
+		s.column[0] = s.read
+	}
+	for i := 1; i < len(s.column); i++ {
+		cost := 1
+		if s.pattern[i-1] == c {
+			cost = 0
+		}
+		above := s.column[i]
+		s.column[i] = min(diagonal+cost, min(above, s.column[i-1])+1)
+		diagonal = above
+	}
+	return s.column[len(s.column)-1]
+}
+
+func reverse(b []byte) []byte {
+	result := make([]byte, len(b))
+	for i, c := range b {
+		result[len(b)-1-i] = c
+	}
+	return result
+}
+
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
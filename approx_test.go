@@ -0,0 +1,125 @@
+//go:build !time
+
+package grep_test
+
+import (
+	"math/rand"
+	"strings"
+	"testing"
+
+	"github.com/alex123012/go-grep"
+)
+
+func TestApproxFinder(t *testing.T) {
+	fileName := writeTestFile(t, "ocr.txt", "Shakespeare\nShakspeare\nShakcspeere\nShake speare\nsomething else\n")
+	patternSearch := grep.MakeApproxFinder("Shakespeare", 1)
+	fileMap, err := patternSearch.Search(fileName, false)
+	if err != nil {
+		t.Fatalf("Error in executing test on %s: %v", fileName, err)
+	}
+
+	v, _ := fileMap.Get(fileName)
+	lines := v.(grep.SyncMap)
+	if len := lines.Len(); len != 3 {
+		t.Fatalf("Expected 3 approximate matches, but got %d", len)
+	}
+	expected := map[int]int{1: 0, 2: 1, 4: 1}
+	for number, distance := range expected {
+		line, f := lines.Get(number)
+		if !f {
+			t.Fatalf("Expected line %d to match", number)
+		}
+		if spans := line.(*grep.Line).Spans; len(spans) != 1 || spans[0].Distance != distance {
+			t.Fatalf("Expected line %d to match with distance %d, but got %+v", number, distance, spans)
+		}
+	}
+}
+
+func TestApproxFindAll(t *testing.T) {
+	random := rand.New(rand.NewSource(1))
+	alphabet := "abc"
+	randomString := func(n int) string {
+		var b strings.Builder
+		for i := 0; i < n; i++ {
+			b.WriteByte(alphabet[random.Intn(len(alphabet))])
+		}
+		return b.String()
+	}
+
+	for i := 0; i < 2000; i++ {
+		// Patterns over 64 bytes go through the Sellers fallback.
+		patternLen := 1 + random.Intn(8)
+		if i%50 == 0 {
+			patternLen = 65 + random.Intn(10)
+		}
+		pattern := randomString(patternLen)
+		text := randomString(random.Intn(2 * patternLen))
+		maxErrors := random.Intn(patternLen/2 + 1)
+
+		spans := grep.MakeApproxFinder(pattern, maxErrors).FindAll([]byte(text), false)
+		best := semiGlobalDistance(pattern, text)
+		if (len(spans) > 0) != (best <= maxErrors) {
+			t.Fatalf("%q in %q with %d errors: expected match %t, but got %v", pattern, text, maxErrors, best <= maxErrors, spans)
+		}
+		for _, span := range spans {
+			if d := editDistance(pattern, text[span.Start:span.End]); d != span.Distance || d > maxErrors {
+				t.Fatalf("%q in %q: span %+v has distance %d", pattern, text, span, d)
+			}
+		}
+		if len(spans) > 0 && spans[0].Distance < best {
+			t.Fatalf("%q in %q: span %+v is better than the best distance %d", pattern, text, spans[0], best)
+		}
+	}
+}
+
+// semiGlobalDistance is the lowest edit distance of pattern against any
+// substring of text.
+func semiGlobalDistance(pattern, text string) int {
+	column := make([]int, len(pattern)+1)
+	for i := range column {
+		column[i] = i
+	}
+	best := len(pattern)
+	for j := 0; j < len(text); j++ {
+		diagonal := column[0]
+		for i := 1; i <= len(pattern); i++ {
+			cost := 1
+			if pattern[i-1] == text[j] {
+				cost = 0
+			}
+			above := column[i]
+			column[i] = minInt(diagonal+cost, minInt(above, column[i-1])+1)
+			diagonal = above
+		}
+		best = minInt(best, column[len(pattern)])
+	}
+	return best
+}
+
+func editDistance(a, b string) int {
+	row := make([]int, len(b)+1)
+	for j := range row {
+		row[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		diagonal := row[0]
+		row[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			above := row[j]
+			row[j] = minInt(diagonal+cost, minInt(above, row[j-1])+1)
+			diagonal = above
+		}
+	}
+	return row[len(b)]
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
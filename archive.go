@@ -0,0 +1,184 @@
+package grep
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io"
+	"io/fs"
+)
+
+// ErrArchiveSize is stored by Search in MapFiles.Errors for an archive from
+// which more than the limit set with SetArchives would be extracted.
+var ErrArchiveSize = errors.New("archive size limit exceeded")
+
+// SetArchives makes Search descend into zip (including jar) and tar archives,
+// optionally gzip-compressed, and search each of their files. The results of
+// a file in an archive are stored under the name of the archive followed by
+// "!/" and the path in the archive, like "release.zip!/docs/readme.txt".
+// Archives inside archives are searched up to maxDepth levels deep. At most
+// maxSize bytes are extracted from each archive, including the archives
+// nested in it; past that the rest of the archive is skipped, while the other
+// files are still searched. The errors of corrupt archives and of the files
+// in them that can't be read are stored in MapFiles.Errors, and the search
+// goes on with the next file. Zip archives that can't be read at random, like
+// those inside other archives, are held in memory and count towards maxSize
+// too. Zero maxDepth, the default, disables archive search.
+func (f *finder) SetArchives(maxDepth int, maxSize int64) {
+	f.archiveDepth = maxDepth
+	f.maxArchiveSize = maxSize
+}
+
+type archiveKind int
+
+const (
+	noArchive archiveKind = iota
+	zipArchive
+	tarArchive
+	// gzipArchive is gzip-compressed data that may hold a tar archive.
+	gzipArchive
+)
+
+// detectArchive peeks at the start of br to tell the kind of archive it
+// holds.
+func detectArchive(br *bufio.Reader) archiveKind {
+	magic, _ := br.Peek(4)
+	switch {
+	case bytes.Equal(magic, []byte("PK\x03\x04")) || bytes.Equal(magic, []byte("PK\x05\x06")):
+		return zipArchive
+	case bytes.HasPrefix(magic, []byte{0x1f, 0x8b}):
+		return gzipArchive
+	case isTar(br):
+		return tarArchive
+	}
+	return noArchive
+}
+
+// tarHeaderLength is how much of the start of a file is read to tell a tar
+// archive, up to the end of the "ustar" magic of its first header.
+const tarHeaderLength = 262
+
+func isTar(br *bufio.Reader) bool {
+	header, _ := br.Peek(tarHeaderLength)
+	return len(header) == tarHeaderLength && bytes.Equal(header[257:262], []byte("ustar"))
+}
+
+// archiveMatch searches the files of the archive name of the given kind, read
+// from br or, for zip, also from file if it allows random access. depth is
+// the nesting level of the archive, counting from 1, and left is the number of
+// bytes that may still be extracted.
+func (f *finder) archiveMatch(name string, file fs.File, br *bufio.Reader, kind archiveKind, depth int, left *int64) error {
+	switch kind {
+	case zipArchive:
+		return f.zipMatch(name, file, br, depth, left)
+	case tarArchive:
+		return f.tarMatch(name, br, depth, left)
+	}
+
+	gz, err := gzip.NewReader(br)
+	if err != nil {
+		return contentError{err}
+	}
+	gzbr := bufio.NewReader(contentReader{gz})
+	// A corrupt stream may end before a tar header would.
+	if _, err := gzbr.Peek(tarHeaderLength); err != nil && err != io.EOF {
+		return err
+	}
+	if isTar(gzbr) {
+		return f.tarMatch(name, gzbr, depth, left)
+	}
+	if f.maxDecompressed <= 0 {
+		// Compressed data is never text.
+		return nil
+	}
+	decompressedLeft := f.maxDecompressed
+	r := &limitedReader{r: gzbr, n: &decompressedLeft, err: ErrDecompressedSize}
+	return f.contentMatch(name, nil, &limitedReader{r: r, n: left, err: ErrArchiveSize})
+}
+
+func (f *finder) zipMatch(name string, file fs.File, br *bufio.Reader, depth int, left *int64) error {
+	var r io.ReaderAt
+	var size int64
+	if readerAt, ok := file.(io.ReaderAt); ok {
+		info, err := file.Stat()
+		if err != nil {
+			return err
+		}
+		r, size = readerAt, info.Size()
+	} else {
+		data, err := io.ReadAll(&limitedReader{r: br, n: left, err: ErrArchiveSize})
+		if err != nil {
+			return err
+		}
+		r, size = bytes.NewReader(data), int64(len(data))
+	}
+
+	archive, err := zip.NewReader(r, size)
+	if err != nil {
+		return contentError{err}
+	}
+	for _, member := range archive.File {
+		if !member.Mode().IsRegular() {
+			continue
+		}
+		memberName := name + "!/" + member.Name
+		rc, err := member.Open()
+		if err != nil {
+			// Like an unsupported compression method.
+			f.mapFiles.putError(memberName, err)
+			continue
+		}
+		err = f.memberMatch(memberName, contentReader{rc}, depth, left)
+		rc.Close()
+		if err := f.memberError(memberName, err); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (f *finder) tarMatch(name string, br *bufio.Reader, depth int, left *int64) error {
+	archive := tar.NewReader(br)
+	for {
+		header, err := archive.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			// The rest of a corrupt tar archive can't be found.
+			return contentError{err}
+		}
+		if !header.FileInfo().Mode().IsRegular() {
+			continue
+		}
+		memberName := name + "!/" + header.Name
+		if err := f.memberError(memberName, f.memberMatch(memberName, contentReader{archive}, depth, left)); err != nil {
+			return err
+		}
+	}
+}
+
+// memberError stores the error of the file name in an archive like fileError,
+// but returns ErrArchiveSize, as the limit applies to the whole archive.
+func (f *finder) memberError(name string, err error) error {
+	if errors.Is(err, ErrArchiveSize) {
+		return err
+	}
+	return f.fileError(name, err)
+}
+
+// memberMatch searches a file of an archive at depth, which may be an archive
+// itself. The bytes of the files searched, and of the archives held in
+// memory, count towards the limit.
+func (f *finder) memberMatch(name string, r io.Reader, depth int, left *int64) error {
+	br := bufio.NewReader(r)
+	if depth < f.archiveDepth {
+		if kind := detectArchive(br); kind != noArchive {
+			return f.archiveMatch(name, nil, br, kind, depth+1, left)
+		}
+	}
+	return f.contentMatch(name, nil, &limitedReader{r: br, n: left, err: ErrArchiveSize})
+}
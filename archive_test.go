@@ -0,0 +1,226 @@
+//go:build !time
+
+package grep_test
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io"
+	"path/filepath"
+	"testing"
+
+	"github.com/alex123012/go-grep"
+)
+
+func makeZip(t *testing.T, files map[string]string) string {
+	t.Helper()
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	for name, content := range files {
+		file, err := w.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := file.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.String()
+}
+
+func makeTarGz(t *testing.T, files map[string]string) string {
+	t.Helper()
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	w := tar.NewWriter(gz)
+	for name, content := range files {
+		header := &tar.Header{Name: name, Mode: 0644, Size: int64(len(content)), Typeflag: tar.TypeReg}
+		if err := w.WriteHeader(header); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.String()
+}
+
+func TestArchives(t *testing.T) {
+	dir := t.TempDir()
+	release := writeFileInDir(t, dir, "release.zip", makeZip(t, map[string]string{
+		"docs/readme.txt": "how to kill it\n",
+		"lib/inner.jar":   makeZip(t, map[string]string{"META-INF/notes.txt": "kill\n"}),
+		"src.tar.gz":      makeTarGz(t, map[string]string{"src/main.go": "package main\n\n// kill\n"}),
+	}))
+	plain := writeFileInDir(t, dir, "plain.txt", "kill\n")
+
+	patternSearch := grep.MakeStringFinder("kill")
+	fileMap, err := patternSearch.Search(dir, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v := fileMap.Len(); v != 1 {
+		t.Fatalf("Expected only plain.txt without archive search, but got %+v", fileMap.GetStruct())
+	}
+
+	patternSearch.SetArchives(2, 1<<20)
+	fileMap, err = patternSearch.Search(dir, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	result := searchResults(fileMap)
+	expected := map[string]int{
+		plain:                         1,
+		release + "!/docs/readme.txt": 1,
+		release + "!/lib/inner.jar!/META-INF/notes.txt": 1,
+		release + "!/src.tar.gz!/src/main.go":           3,
+	}
+	if len(result) != len(expected) {
+		t.Fatalf("Expected matches in %d files, but got %+v", len(expected), result)
+	}
+	for name, number := range expected {
+		if _, f := result[name][number]; !f {
+			t.Fatalf("Expected line %d of %s, but got %+v", number, name, result)
+		}
+	}
+
+	patternSearch.SetArchives(1, 1<<20)
+	fileMap, err = patternSearch.Search(release, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, f := fileMap.Get(release + "!/docs/readme.txt"); !f || fileMap.Len() != 1 {
+		t.Fatalf("Expected only the top level of %s, but got %+v", filepath.Base(release), fileMap.GetStruct())
+	}
+
+	patternSearch.SetArchives(2, 16)
+	other := writeFileInDir(t, filepath.Dir(release), "other.txt", "kill\n")
+	fileMap, err = patternSearch.Search(filepath.Dir(release), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := fileMap.Errors()[release]; !errors.Is(err, grep.ErrArchiveSize) {
+		t.Fatalf("Expected %v, but got %v", grep.ErrArchiveSize, err)
+	}
+	if _, f := fileMap.Get(other); !f {
+		t.Fatalf("Expected %s to be searched, but got %+v", other, fileMap.GetStruct())
+	}
+}
+
+func TestArchivesTar(t *testing.T) {
+	var buf bytes.Buffer
+	w := tar.NewWriter(&buf)
+	for _, header := range []*tar.Header{
+		{Name: "dir/", Mode: 0755, Typeflag: tar.TypeDir},
+		{Name: "dir/file.txt", Mode: 0644, Size: 5, Typeflag: tar.TypeReg},
+	} {
+		if err := w.WriteHeader(header); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := w.Write([]byte("kill\n")); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	fileName := writeTestFile(t, "plain.tar", buf.String())
+
+	patternSearch := grep.MakeStringFinder("kill")
+	patternSearch.SetArchives(1, 1<<20)
+	fileMap, err := patternSearch.Search(fileName, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, f := fileMap.Get(fileName + "!/dir/file.txt"); !f || fileMap.Len() != 1 {
+		t.Fatalf("Expected only dir/file.txt, but got %+v", fileMap.GetStruct())
+	}
+}
+
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error {
+	return nil
+}
+
+func TestArchivesCorrupt(t *testing.T) {
+	// A zip archive with a file compressed by a method unknown to readers.
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	w.RegisterCompressor(99, func(out io.Writer) (io.WriteCloser, error) {
+		return nopWriteCloser{out}, nil
+	})
+	for _, header := range []*zip.FileHeader{{Name: "odd.txt", Method: 99}, {Name: "ok.txt", Method: zip.Deflate}} {
+		file, err := w.CreateHeader(header)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := file.Write([]byte("kill\n")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	var tarBuf bytes.Buffer
+	tw := tar.NewWriter(&tarBuf)
+	if err := tw.WriteHeader(&tar.Header{Name: "file.txt", Mode: 0644, Size: 1000, Typeflag: tar.TypeReg}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tw.Write(bytes.Repeat([]byte("kill\n"), 200)); err != nil {
+		t.Fatal(err)
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	tarGz := makeTarGz(t, map[string]string{"file.txt": "kill\n"})
+
+	dir := t.TempDir()
+	badZip := writeFileInDir(t, dir, "bad.zip", "PK\x03\x04garbage")
+	methodZip := writeFileInDir(t, dir, "method.zip", buf.String())
+	badTar := writeFileInDir(t, dir, "bad.tar", tarBuf.String()[:600])
+	badTarGz := writeFileInDir(t, dir, "bad.tar.gz", tarGz[:len(tarGz)/2])
+	other := writeFileInDir(t, dir, "other.txt", "kill\n")
+
+	patternSearch := grep.MakeStringFinder("kill")
+	patternSearch.SetArchives(1, 1<<20)
+	fileMap, err := patternSearch.Search(dir, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	errs := fileMap.Errors()
+	for name, expected := range map[string]error{
+		badZip:                  zip.ErrFormat,
+		methodZip + "!/odd.txt": zip.ErrAlgorithm,
+		badTar + "!/file.txt":   io.ErrUnexpectedEOF,
+		badTar:                  io.ErrUnexpectedEOF,
+		badTarGz:                io.ErrUnexpectedEOF,
+	} {
+		if err := errs[name]; !errors.Is(err, expected) {
+			t.Errorf("Expected %v for %s, but got %v", expected, name, err)
+		}
+	}
+	for _, name := range []string{methodZip + "!/ok.txt", other} {
+		if _, f := fileMap.Get(name); !f {
+			t.Errorf("Expected %s to be searched, but got %+v", name, fileMap.GetStruct())
+		}
+	}
+	if fileMap.Len() != 2 {
+		t.Errorf("Expected only 2 files to match, but got %+v", fileMap.GetStruct())
+	}
+}
@@ -0,0 +1,113 @@
+package grep
+
+import (
+	"bytes"
+	"unicode/utf8"
+)
+
+// BinaryMode tells Search what to do with binary files, which are files
+// holding NUL bytes or invalid UTF-8.
+type BinaryMode int
+
+const (
+	// BinarySkip skips binary files. It is the default.
+	BinarySkip BinaryMode = iota
+	// BinaryText searches binary files like text files.
+	BinaryText
+	// BinaryMatches stores binary files that match, like grep reports
+	// "binary file matches": they hold no lines and MapFiles.Binary
+	// reports them. Reading a binary file stops at its first match.
+	BinaryMatches
+)
+
+// DefaultBinaryWindow is how many bytes from the start of a file are checked
+// to tell whether it is binary, unless SetBinaryWindow is called.
+const DefaultBinaryWindow = 8 << 10
+
+// SetBinaryMode sets what Search does with binary files. Binary search, see
+// SetBinarySearch, reads all files as binary regardless of the mode.
+func (f *finder) SetBinaryMode(mode BinaryMode) {
+	f.binaryMode = mode
+}
+
+// SetBinaryWindow sets how many bytes from the start of each file are checked
+// for NUL bytes and invalid UTF-8. Zero or a negative size checks the whole
+// file, so a file may be found binary only after some of its lines are
+// passed to SearchReaderFunc.
+func (f *finder) SetBinaryWindow(size int) {
+	f.binaryWindow = size
+}
+
+// binaryDetector checks the pieces of a file, as they are read, for binary
+// data.
+type binaryDetector struct {
+	// left is the number of bytes still to be checked, if whole is false.
+	left  int
+	whole bool
+}
+
+// newBinaryDetector returns nil if binary files are searched as text.
+func (f *finder) newBinaryDetector() *binaryDetector {
+	if f.binaryMode == BinaryText {
+		return nil
+	}
+	return &binaryDetector{left: f.binaryWindow, whole: f.binaryWindow <= 0}
+}
+
+// check reports whether segment, the next piece of the file, is binary.
+func (d *binaryDetector) check(segment lineSegment) bool {
+	if d == nil || (!d.whole && d.left <= 0) {
+		return false
+	}
+	text, cutEnd := segment.text, !segment.last
+	if !d.whole && len(text) > d.left {
+		text, cutEnd = text[:d.left], true
+	}
+	// Count the newline too.
+	d.left -= len(text) + 1
+	return isBinary(text, segment.offset > 0, cutEnd)
+}
+
+// isBinary reports whether text holds a NUL byte or invalid UTF-8. If text
+// is cut out of a longer one, a rune split at its start or end is ignored.
+func isBinary(text []byte, cutStart, cutEnd bool) bool {
+	if bytes.IndexByte(text, 0) != -1 {
+		return true
+	}
+	for i := 0; cutStart && i < utf8.UTFMax-1 && len(text) > 0 && !utf8.RuneStart(text[0]); i++ {
+		text = text[1:]
+	}
+	if cutEnd {
+		for i := len(text) - 1; i >= 0 && i >= len(text)-utf8.UTFMax+1; i-- {
+			if utf8.RuneStart(text[i]) {
+				if !utf8.FullRune(text[i:]) {
+					text = text[:i]
+				}
+				break
+			}
+		}
+	}
+	return !utf8.Valid(text)
+}
+
+// bufferBinary reports whether buf, a whole file, is binary.
+func (f *finder) bufferBinary(buf []byte) bool {
+	if f.binaryMode == BinaryText {
+		return false
+	}
+	cut := f.binaryWindow > 0 && len(buf) > f.binaryWindow
+	if cut {
+		buf = buf[:f.binaryWindow]
+	}
+	if bytes.IndexByte(f.records.separator, 0) == -1 {
+		return isBinary(buf, false, cut)
+	}
+	// The NUL bytes of the separators don't make records binary.
+	records := bytes.Split(buf, f.records.separator)
+	for i, record := range records {
+		if isBinary(record, false, cut && i == len(records)-1) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,89 @@
+//go:build !time
+
+package grep_test
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/alex123012/go-grep"
+)
+
+func TestBinaryModes(t *testing.T) {
+	dir := t.TempDir()
+	writeFileInDir(t, dir, "escapes.log", "\x1b[31mkill\x1b[0m\nkill\n")
+	writeFileInDir(t, dir, "nul.bin", "kill text\n\x00\x01kill\n")
+	writeFileInDir(t, dir, "latin1.txt", "caf\xe9 kill\n")
+	writeFileInDir(t, dir, "late.txt", "kill\n"+strings.Repeat("text\n", 4000)+"\x00kill\n")
+
+	for _, threshold := range []int64{0, 1} {
+		patternSearch := grep.MakeStringFinder("kill")
+		patternSearch.SetMmapThreshold(threshold)
+
+		fileMap, err := patternSearch.Search(dir, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		result := searchResults(fileMap)
+		if len(result) != 2 || len(result[filepath.Join(dir, "escapes.log")]) != 2 || len(result[filepath.Join(dir, "late.txt")]) != 2 {
+			t.Fatalf("Expected the 2 text files, but got %+v", result)
+		}
+
+		patternSearch.SetBinaryWindow(0)
+		fileMap, err = patternSearch.Search(dir, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, f := fileMap.Get(filepath.Join(dir, "escapes.log")); !f || fileMap.Len() != 1 {
+			t.Fatalf("Expected only escapes.log checking whole files, but got %+v", fileMap.GetStruct())
+		}
+
+		patternSearch.SetBinaryMode(grep.BinaryMatches)
+		fileMap, err = patternSearch.Search(dir, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if v := fileMap.Len(); v != 4 {
+			t.Fatalf("Expected 4 files, but got %+v", fileMap.GetStruct())
+		}
+		for _, file := range fileMap.GetStruct() {
+			binary := !strings.HasSuffix(file.Name, "escapes.log")
+			if file.Binary != binary || fileMap.Binary(file.Name) != binary || binary && len(file.Lines) != 0 {
+				t.Fatalf("Expected %s to be binary %v, but got %+v", file.Name, binary, file)
+			}
+		}
+
+		patternSearch.SetBinaryMode(grep.BinaryText)
+		fileMap, err = patternSearch.Search(dir, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		result = searchResults(fileMap)
+		if len(result) != 4 || result[filepath.Join(dir, "nul.bin")][2].Text != "\x00\x01kill" {
+			t.Fatalf("Expected all 4 files as text, but got %+v", result)
+		}
+	}
+}
+
+func TestBinaryModeInvert(t *testing.T) {
+	fileName := writeTestFile(t, "nul.bin", "text\n\x00\n")
+	patternSearch := grep.MakeStringFinder("kill")
+	patternSearch.SetInvert(true)
+	fileMap, err := patternSearch.Search(fileName, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v := fileMap.Len(); v != 0 {
+		t.Fatalf("Expected binary files to be skipped, but got %d", v)
+	}
+
+	patternSearch.SetBinaryMode(grep.BinaryMatches)
+	fileMap, err = patternSearch.Search(fileName, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !fileMap.Binary(fileName) {
+		t.Fatalf("Expected %s to match as a binary file, but got %+v", fileName, fileMap.GetStruct())
+	}
+}
@@ -0,0 +1,59 @@
+package grep
+
+import (
+	"unicode"
+	"unicode/utf8"
+)
+
+// BoundaryMode defines what must surround a StringFinder match.
+type BoundaryMode int
+
+const (
+	// AnyBoundary accepts a match anywhere in the line. It is the default
+	// mode.
+	AnyBoundary BoundaryMode = iota
+	// WordBoundaryASCII accepts a match that is neither preceded nor
+	// followed by an ASCII letter, digit or underscore.
+	WordBoundaryASCII
+	// WordBoundaryUnicode accepts a match that is neither preceded nor
+	// followed by a Unicode letter, digit or underscore.
+	WordBoundaryUnicode
+	// WholeLine accepts a match only if it spans the whole record, see
+	// SetRecordMode, or in multiline mode whole lines.
+	WholeLine
+)
+
+// SetBoundary sets what must surround a match for it to count, like the -w
+// and -x flags of grep.
+func (f *StringFinder) SetBoundary(mode BoundaryMode) {
+	f.boundary = mode
+}
+
+// atBoundary reports whether text[start:end] satisfies f.boundary.
+func (f *StringFinder) atBoundary(text []byte, start, end int) bool {
+	switch f.boundary {
+	case WordBoundaryASCII:
+		return (start == 0 || !isWordByte(text[start-1])) &&
+			(end == len(text) || !isWordByte(text[end]))
+	case WordBoundaryUnicode:
+		before, _ := utf8.DecodeLastRune(text[:start])
+		after, _ := utf8.DecodeRune(text[end:])
+		return (start == 0 || !isWordRune(before)) &&
+			(end == len(text) || !isWordRune(after))
+	case WholeLine:
+		// Only multiline text holds many lines; a newline inside a record
+		// of another mode is part of it.
+		lines := f.finder != nil && f.multiline
+		return (start == 0 || lines && text[start-1] == '\n') &&
+			(end == len(text) || lines && text[end] == '\n')
+	}
+	return true
+}
+
+func isWordByte(b byte) bool {
+	return b == '_' || '0' <= b && b <= '9' || 'a' <= b && b <= 'z' || 'A' <= b && b <= 'Z'
+}
+
+func isWordRune(r rune) bool {
+	return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
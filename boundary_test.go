@@ -0,0 +1,55 @@
+//go:build !time
+
+package grep_test
+
+import (
+	"testing"
+
+	"github.com/alex123012/go-grep"
+)
+
+func TestBoundary(t *testing.T) {
+	fileName := writeTestFile(t, "words.txt", "id\nvalid\nidle\nuuid\nthe id is\nid_x\nid id2 id\nйid\n")
+	testCases := []struct {
+		mode      grep.BoundaryMode
+		grepCount int
+	}{
+		{mode: grep.AnyBoundary, grepCount: 8},
+		{mode: grep.WordBoundaryASCII, grepCount: 4},
+		{mode: grep.WordBoundaryUnicode, grepCount: 3},
+		{mode: grep.WholeLine, grepCount: 1},
+	}
+	for _, testCase := range testCases {
+		patternSearch := grep.MakeStringFinder("id")
+		patternSearch.SetBoundary(testCase.mode)
+		if len := countLines(t, patternSearch, fileName); len != testCase.grepCount {
+			t.Fatalf("Expected %d lines with boundary mode %d, but got %d", testCase.grepCount, testCase.mode, len)
+		}
+	}
+}
+
+func TestBoundaryKeepsSearching(t *testing.T) {
+	patternSearch := grep.MakeStringFinder("ID")
+	patternSearch.SetCaseMode(grep.CaseInsensitive)
+	patternSearch.SetBoundary(grep.WordBoundaryASCII)
+	spans := patternSearch.FindAll([]byte("valid idle Id uuid ID"), false)
+	if len(spans) != 2 || spans[0] != (grep.Span{Start: 11, End: 13}) || spans[1] != (grep.Span{Start: 19, End: 21}) {
+		t.Fatalf("Expected 2 whole-word spans, but got %v", spans)
+	}
+
+	patternSearch = grep.MakeStringFinder("é")
+	patternSearch.SetCaseMode(grep.CaseInsensitive)
+	patternSearch.SetBoundary(grep.WordBoundaryUnicode)
+	spans = patternSearch.FindAll([]byte("café É"), false)
+	if len(spans) != 1 || spans[0] != (grep.Span{Start: 6, End: 8}) {
+		t.Fatalf("Expected 1 whole-word span, but got %v", spans)
+	}
+
+	// The match after an accepted one is checked against the byte before it.
+	patternSearch = grep.MakeStringFinder("-x")
+	patternSearch.SetBoundary(grep.WordBoundaryASCII)
+	spans = patternSearch.FindAll([]byte("a -x-x"), false)
+	if len(spans) != 1 || spans[0] != (grep.Span{Start: 2, End: 4}) {
+		t.Fatalf("Expected only the first span, but got %v", spans)
+	}
+}
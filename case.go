@@ -0,0 +1,171 @@
+package grep
+
+import (
+	"bytes"
+	"unicode"
+	"unicode/utf8"
+)
+
+// CaseMode defines how StringFinder compares letters of different case.
+type CaseMode int
+
+const (
+	// CaseSensitive matches bytes exactly. It is the default mode.
+	CaseSensitive CaseMode = iota
+	// CaseInsensitive matches letters regardless of case, using Unicode
+	// simple case folding.
+	CaseInsensitive
+	// SmartCase is CaseInsensitive when the pattern has no upper case
+	// letters and CaseSensitive otherwise.
+	SmartCase
+)
+
+type foldKind int
+
+const (
+	noFold foldKind = iota
+	// asciiFold is used for ASCII patterns: the pattern is lowered and the
+	// skip tables hold both cases, so Boyer-Moore still applies. Since k and
+	// s also fold to the Kelvin sign and the long s, patterns with them are
+	// searched as unicodeFold in text that holds those.
+	asciiFold
+	// unicodeFold is used for patterns with non-ASCII letters, whose folded
+	// forms may have a different length in UTF-8.
+	unicodeFold
+)
+
+// SetCaseMode rebuilds the search tables of f for the given case mode.
+func (f *StringFinder) SetCaseMode(mode CaseMode) {
+	pattern := []byte(f.source)
+	f.fold = noFold
+	f.nonASCIIFolds = false
+	if mode == CaseInsensitive || (mode == SmartCase && !hasUpper(pattern)) {
+		if isASCII(pattern) {
+			f.fold = asciiFold
+			pattern = bytes.ToLower(pattern)
+			f.nonASCIIFolds = bytes.ContainsAny(pattern, "ks")
+		} else {
+			f.fold = unicodeFold
+		}
+	}
+	f.buildTables(pattern)
+}
+
+// findASCIIFold is find for asciiFold patterns.
+func (f *StringFinder) findASCIIFold(text []byte, from int, bounded bool) int {
+	i := f.searchASCIIFold(text, from, bounded)
+	if !f.nonASCIIFolds {
+		return i
+	}
+	// A match with the Kelvin sign or the long s that starts before i has
+	// them within 3 bytes for each byte of the pattern.
+	end := len(text)
+	if i != -1 && i+3*f.patternLen < end {
+		end = i + 3*f.patternLen
+	}
+	if bytes.Contains(text[from:end], kelvinSign) || bytes.Contains(text[from:end], longS) {
+		return f.searchUnicodeFold(text, from, bounded)
+	}
+	return i
+}
+
+var (
+	kelvinSign = []byte("\u212a")
+	longS      = []byte("\u017f")
+)
+
+// searchASCIIFold is search for asciiFold patterns.
+func (f *StringFinder) searchASCIIFold(text []byte, from int, bounded bool) int {
+	i := from + f.patternLen - 1
+	for i < len(text) {
+		j := f.patternLen - 1
+		for j >= 0 && toLowerASCII(text[i]) == f.pattern[j] {
+			i--
+			j--
+		}
+		if j < 0 {
+			if !bounded || f.atBoundary(text, i+1, i+1+f.patternLen) {
+				return i + 1
+			}
+			i += f.patternLen + 1
+			continue
+		}
+		i += max(f.badCharSkip[text[i]], f.goodSuffixSkip[j])
+	}
+	return -1
+}
+
+// searchUnicodeFold tries the pattern at every rune boundary of text.
+func (f *StringFinder) searchUnicodeFold(text []byte, from int, bounded bool) int {
+	for i := from; i < len(text); {
+		if n := prefixFoldLen(text[i:], f.pattern); n != -1 && (!bounded || f.atBoundary(text, i, i+n)) {
+			return i
+		}
+		_, size := utf8.DecodeRune(text[i:])
+		i += size
+	}
+	return -1
+}
+
+// prefixFoldLen returns the length of the beginning of s that equals prefix
+// under Unicode simple case folding, or -1 if s doesn't begin with prefix.
+func prefixFoldLen(s, prefix []byte) int {
+	n := 0
+	for len(prefix) > 0 {
+		if len(s) == 0 {
+			return -1
+		}
+		r1, size1 := utf8.DecodeRune(prefix)
+		r2, size2 := utf8.DecodeRune(s)
+		if !equalFoldRune(r1, r2) {
+			return -1
+		}
+		prefix, s = prefix[size1:], s[size2:]
+		n += size2
+	}
+	return n
+}
+
+func equalFoldRune(a, b rune) bool {
+	if a == b {
+		return true
+	}
+	for r := unicode.SimpleFold(a); r != a; r = unicode.SimpleFold(r) {
+		if r == b {
+			return true
+		}
+	}
+	return false
+}
+
+func hasUpper(pattern []byte) bool {
+	for _, r := range string(pattern) {
+		if unicode.IsUpper(r) {
+			return true
+		}
+	}
+	return false
+}
+
+func isASCII(pattern []byte) bool {
+	for _, b := range pattern {
+		if b >= utf8.RuneSelf {
+			return false
+		}
+	}
+	return true
+}
+
+func toLowerASCII(b byte) byte {
+	if 'A' <= b && b <= 'Z' {
+		return b + 'a' - 'A'
+	}
+	return b
+}
+
+func toUpperASCII(b byte) byte {
+	if 'a' <= b && b <= 'z' {
+		return b - ('a' - 'A')
+	}
+	return b
+}
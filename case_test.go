@@ -0,0 +1,97 @@
+//go:build !time
+
+package grep_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/alex123012/go-grep"
+)
+
+func TestCaseModes(t *testing.T) {
+	fileName := "./test_cases/test_dir/test_short_text_file.txt"
+	testCases := []struct {
+		pattern   string
+		mode      grep.CaseMode
+		grepCount int
+	}{
+		{pattern: "a0a", mode: grep.CaseSensitive, grepCount: 0},
+		{pattern: "a0a", mode: grep.CaseInsensitive, grepCount: 13},
+		{pattern: "a0a", mode: grep.SmartCase, grepCount: 13},
+		{pattern: "A0a", mode: grep.SmartCase, grepCount: 0},
+		{pattern: "A0A", mode: grep.SmartCase, grepCount: 13},
+		{pattern: "q9dg71", mode: grep.CaseInsensitive, grepCount: 1},
+	}
+	for _, testCase := range testCases {
+		patternSearch := grep.MakeStringFinder(testCase.pattern)
+		patternSearch.SetCaseMode(testCase.mode)
+		if len := countLines(t, patternSearch, fileName); len != testCase.grepCount {
+			t.Fatalf("Expected %d lines for %q with case mode %d, but got %d",
+				testCase.grepCount, testCase.pattern, testCase.mode, len)
+		}
+	}
+}
+
+func TestCaseUnicode(t *testing.T) {
+	fileName := writeTestFile(t, "unicode.txt", "Straße\nSTRAẞE\nstrasse\nΣΊΣΥΦΟΣ\nσίσυφος\n")
+	testCases := []struct {
+		pattern   string
+		mode      grep.CaseMode
+		grepCount int
+	}{
+		{pattern: "straße", mode: grep.CaseSensitive, grepCount: 0},
+		{pattern: "straße", mode: grep.CaseInsensitive, grepCount: 2},
+		{pattern: "straße", mode: grep.SmartCase, grepCount: 2},
+		{pattern: "ΣΊΣ", mode: grep.SmartCase, grepCount: 1},
+		{pattern: "σίσ", mode: grep.SmartCase, grepCount: 2},
+	}
+	for _, testCase := range testCases {
+		patternSearch := grep.MakeStringFinder(testCase.pattern)
+		patternSearch.SetCaseMode(testCase.mode)
+		if len := countLines(t, patternSearch, fileName); len != testCase.grepCount {
+			t.Fatalf("Expected %d lines for %q with case mode %d, but got %d",
+				testCase.grepCount, testCase.pattern, testCase.mode, len)
+		}
+	}
+}
+
+func TestCaseKelvinAndLongS(t *testing.T) {
+	// The Kelvin sign folds to k and the long s to s.
+	fileName := writeTestFile(t, "fold.txt", "\u212a\nmask\nma\u017fk\nMASK\nmap\n")
+	testCases := []struct {
+		pattern   string
+		grepCount int
+	}{
+		{pattern: "k", grepCount: 4},
+		{pattern: "mask", grepCount: 3},
+		{pattern: "ma", grepCount: 4},
+	}
+	for _, testCase := range testCases {
+		patternSearch := grep.MakeStringFinder(testCase.pattern)
+		patternSearch.SetCaseMode(grep.CaseInsensitive)
+		if len := countLines(t, patternSearch, fileName); len != testCase.grepCount {
+			t.Fatalf("Expected %d lines for %q, but got %d", testCase.grepCount, testCase.pattern, len)
+		}
+	}
+
+	patternSearch := grep.MakeStringFinder("sk")
+	patternSearch.SetCaseMode(grep.CaseInsensitive)
+	expected := []grep.Span{{Start: 2, End: 4}, {Start: 4, End: 6}, {Start: 9, End: 14}}
+	if spans := patternSearch.FindAll([]byte("masksk ma\u017f\u212a"), false); !reflect.DeepEqual(spans, expected) {
+		t.Fatalf("Expected %v, but got %v", expected, spans)
+	}
+}
+
+func countLines(t *testing.T, patternSearch *grep.StringFinder, fileName string) int {
+	t.Helper()
+	fileMap, err := patternSearch.Search(fileName, false)
+	if err != nil {
+		t.Fatalf("Error in executing test on %s: %v", fileName, err)
+	}
+	v, f := fileMap.Get(fileName)
+	if !f {
+		return 0
+	}
+	return v.(grep.SyncMap).Len()
+}
@@ -1,5 +1,11 @@
 package grep_test
 
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
 type TestCase struct {
 	fileName     string
 	pattern      string
@@ -7,3 +13,24 @@ type TestCase struct {
 	grepLastLine int32
 	onlyFiles    bool
 }
+
+// writeTestFile creates a file with content in a temporary directory and
+// returns its path.
+func writeTestFile(t *testing.T, name, content string) string {
+	t.Helper()
+	return writeFileInDir(t, t.TempDir(), name, content)
+}
+
+// writeFileInDir creates a file with content in dir, creating the parent
+// directories of name as needed, and returns its path.
+func writeFileInDir(t *testing.T, dir, name, content string) string {
+	t.Helper()
+	fileName := filepath.Join(dir, name)
+	if err := os.MkdirAll(filepath.Dir(fileName), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(fileName, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return fileName
+}
@@ -0,0 +1,123 @@
+package grep
+
+import (
+	"bufio"
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"compress/zlib"
+	"errors"
+	"io"
+)
+
+// ErrDecompressedSize is stored by Search in MapFiles.Errors for a compressed
+// file that holds more than the limit set with SetDecompression, and returned
+// by SearchReader for such a stream.
+var ErrDecompressedSize = errors.New("decompressed size limit exceeded")
+
+// SetDecompression makes Search decompress gzip, bzip2 and zlib files, which
+// are recognized by their first bytes, and search their content instead. At
+// most maxSize bytes are decompressed from each file; a file holding more, or
+// a corrupt one, is left out of the results with its error stored in
+// MapFiles.Errors, and the other files are still searched. Zero, the default,
+// disables decompression.
+func (f *finder) SetDecompression(maxSize int64) {
+	f.maxDecompressed = maxSize
+}
+
+// decompress returns a reader of the decompressed content of r, and
+// compressed set, if decompression is enabled and r is compressed. Otherwise
+// the returned reader reads r as is.
+func (f *finder) decompress(r io.Reader) (_ io.Reader, compressed bool, err error) {
+	if f.maxDecompressed <= 0 {
+		return r, false, nil
+	}
+	br, magic := sniffMagic(r, 2, []byte("BZh9"))
+
+	var decompressed io.Reader
+	switch {
+	case bytes.HasPrefix(magic, []byte{0x1f, 0x8b}):
+		decompressed, err = gzip.NewReader(br)
+	case len(magic) == 4 && bytes.HasPrefix(magic, []byte("BZh")) && '1' <= magic[3] && magic[3] <= '9':
+		decompressed = bzip2.NewReader(br)
+	case isZlib(magic, br):
+		decompressed, err = zlib.NewReader(br)
+	default:
+		return br, false, nil
+	}
+	if err != nil {
+		return nil, false, contentError{err}
+	}
+	left := f.maxDecompressed
+	return &limitedReader{r: contentReader{decompressed}, n: &left, err: ErrDecompressedSize}, true, nil
+}
+
+// contentError is an error in the content of a file, like a corrupt
+// compressed stream, which stops the search of that file only.
+type contentError struct {
+	err error
+}
+
+func (e contentError) Error() string {
+	return e.err.Error()
+}
+
+func (e contentError) Unwrap() error {
+	return e.err
+}
+
+// contentReader reads from r, returning its errors other than io.EOF as
+// contentErrors.
+type contentReader struct {
+	r io.Reader
+}
+
+func (r contentReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	if err != nil && err != io.EOF {
+		err = contentError{err}
+	}
+	return n, err
+}
+
+// isZlib reports whether magic is a zlib header without a preset dictionary,
+// as written by compress/zlib, and the data buffered in br after it can be
+// decompressed. Text rarely starts with a valid header, but "x^" is one.
+func isZlib(magic []byte, br *bufio.Reader) bool {
+	if len(magic) < 2 || magic[0] != 0x78 || magic[1]&0x20 != 0 || (uint(magic[0])<<8|uint(magic[1]))%31 != 0 {
+		return false
+	}
+	buffered, _ := br.Peek(br.Buffered())
+	r, err := zlib.NewReader(bytes.NewReader(buffered))
+	if err == nil {
+		_, err = io.Copy(io.Discard, r)
+	}
+	// The buffered data may end in the middle of the stream.
+	return err == nil || errors.Is(err, io.ErrUnexpectedEOF)
+}
+
+// limitedReader reads from r while *n, which may be shared by several
+// readers, is positive, counting down the bytes read. It fails with err if r
+// holds more.
+type limitedReader struct {
+	r   io.Reader
+	n   *int64
+	err error
+}
+
+func (l *limitedReader) Read(p []byte) (int, error) {
+	if *l.n <= 0 {
+		// Check that r really holds more.
+		n, err := l.r.Read(make([]byte, 1))
+		if n > 0 {
+			return 0, l.err
+		}
+		return 0, err
+	}
+	if int64(len(p)) > *l.n {
+		p = p[:*l.n]
+	}
+	n, err := l.r.Read(p)
+	*l.n -= int64(n)
+	return n, err
+}
@@ -0,0 +1,151 @@
+//go:build !time
+
+package grep_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"errors"
+	"io"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/alex123012/go-grep"
+)
+
+const compressedText = "first line\nkill me\n"
+
+// bzip2Text is compressedText compressed with bzip2, which has no writer in
+// the standard library.
+var bzip2Text = []byte{
+	0x42, 0x5a, 0x68, 0x39, 0x31, 0x41, 0x59, 0x26, 0x53, 0x59, 0x0a, 0xdf,
+	0xe3, 0x98, 0x00, 0x00, 0x03, 0x51, 0x80, 0x00, 0x10, 0x40, 0x00, 0x03,
+	0x2f, 0x1c, 0x00, 0x20, 0x00, 0x22, 0x00, 0x32, 0x04, 0x0d, 0x03, 0x41,
+	0xd3, 0xb0, 0x1a, 0x32, 0x21, 0xc1, 0x66, 0x4d, 0xef, 0x17, 0x72, 0x45,
+	0x38, 0x50, 0x90, 0x0a, 0xdf, 0xe3, 0x98,
+}
+
+func compress(t *testing.T, newWriter func(w io.Writer) io.WriteCloser, text string) string {
+	t.Helper()
+	var buf bytes.Buffer
+	w := newWriter(&buf)
+	if _, err := io.WriteString(w, text); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.String()
+}
+
+func TestDecompression(t *testing.T) {
+	dir := t.TempDir()
+	writeFileInDir(t, dir, "log.gz", compress(t, func(w io.Writer) io.WriteCloser {
+		return gzip.NewWriter(w)
+	}, compressedText))
+	writeFileInDir(t, dir, "log.zz", compress(t, func(w io.Writer) io.WriteCloser {
+		return zlib.NewWriter(w)
+	}, compressedText))
+	writeFileInDir(t, dir, "log.bz2", string(bzip2Text))
+	writeFileInDir(t, dir, "log.txt", compressedText)
+	writeFileInDir(t, dir, "caret.txt", "x^2 is kill\n")
+
+	patternSearch := grep.MakeStringFinder("kill")
+	fileMap, err := patternSearch.Search(dir, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v := fileMap.Len(); v != 2 {
+		t.Fatalf("Expected only the 2 text files without decompression, but got %d", v)
+	}
+
+	patternSearch.SetDecompression(1 << 20)
+	fileMap, err = patternSearch.Search(dir, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	result := searchResults(fileMap)
+	if len(result) != 5 {
+		t.Fatalf("Expected matches in 5 files, but got %+v", result)
+	}
+	for name, lines := range result {
+		if !strings.HasSuffix(name, "caret.txt") && lines[2].Text != "kill me" {
+			t.Fatalf("Expected line 2 of %s to match, but got %+v", name, lines)
+		}
+	}
+}
+
+func TestDecompressionLimit(t *testing.T) {
+	text := strings.Repeat("kill\n", 1000)
+	content := compress(t, func(w io.Writer) io.WriteCloser {
+		return gzip.NewWriter(w)
+	}, text)
+	fileName := writeTestFile(t, "bomb.gz", content)
+
+	patternSearch := grep.MakeStringFinder("kill")
+	patternSearch.SetDecompression(int64(len(text)) - 1)
+	other := writeFileInDir(t, filepath.Dir(fileName), "other.txt", "kill\n")
+	fileMap, err := patternSearch.Search(filepath.Dir(fileName), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := fileMap.Errors()[fileName]; !errors.Is(err, grep.ErrDecompressedSize) {
+		t.Fatalf("Expected %v, but got %v", grep.ErrDecompressedSize, err)
+	}
+	if _, f := fileMap.Get(fileName); f || fileMap.Len() != 1 {
+		t.Fatalf("Expected only %s to be searched, but got %+v", other, fileMap.GetStruct())
+	}
+	if _, err := patternSearch.SearchReader("bomb", strings.NewReader(content)); !errors.Is(err, grep.ErrDecompressedSize) {
+		t.Fatalf("Expected %v from SearchReader, but got %v", grep.ErrDecompressedSize, err)
+	}
+
+	patternSearch.SetDecompression(int64(len(text)))
+	fileMap, err = patternSearch.Search(fileName, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v, _ := fileMap.Get(fileName); v.(grep.SyncMap).Len() != 1000 {
+		t.Fatalf("Expected 1000 lines, but got %d", v.(grep.SyncMap).Len())
+	}
+}
+
+func TestDecompressionCorrupt(t *testing.T) {
+	dir := t.TempDir()
+	content := compress(t, func(w io.Writer) io.WriteCloser {
+		return gzip.NewWriter(w)
+	}, compressedText)
+	bad := writeFileInDir(t, dir, "bad.gz", "\x1f\x8b\x01\x02\x03")
+	truncated := writeFileInDir(t, dir, "truncated.gz", content[:len(content)-4])
+	other := writeFileInDir(t, dir, "other.txt", "kill\n")
+
+	patternSearch := grep.MakeStringFinder("kill")
+	patternSearch.SetDecompression(1 << 20)
+	fileMap, err := patternSearch.Search(dir, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	errs := fileMap.Errors()
+	if err := errs[bad]; !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Fatalf("Expected %v for %s, but got %v", io.ErrUnexpectedEOF, bad, err)
+	}
+	if err := errs[truncated]; !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Fatalf("Expected %v for %s, but got %v", io.ErrUnexpectedEOF, truncated, err)
+	}
+	if _, f := fileMap.Get(other); !f || fileMap.Len() != 1 {
+		t.Fatalf("Expected only %s to be found, but got %+v", other, fileMap.GetStruct())
+	}
+}
+
+func TestDecompressionReader(t *testing.T) {
+	patternSearch := grep.MakeStringFinder("kill")
+	patternSearch.SetDecompression(1 << 20)
+	fileMap, err := patternSearch.SearchReader("(standard input)", bytes.NewReader(bzip2Text))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v, f := fileMap.Get("(standard input)"); !f || v.(grep.SyncMap).Len() != 1 {
+		t.Fatalf("Expected 1 line, but got %+v", fileMap.GetStruct())
+	}
+}
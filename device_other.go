@@ -0,0 +1,10 @@
+//go:build !(aix || darwin || dragonfly || freebsd || linux || netbsd || openbsd || solaris)
+
+package grep
+
+import "io/fs"
+
+// fileID reports no device and inode, as this system has no syscall.Stat_t.
+func fileID(info fs.FileInfo) (device, inode uint64, ok bool) {
+	return 0, 0, false
+}
@@ -0,0 +1,17 @@
+//go:build aix || darwin || dragonfly || freebsd || linux || netbsd || openbsd || solaris
+
+package grep
+
+import (
+	"io/fs"
+	"syscall"
+)
+
+// fileID returns the device that holds the file of info and its inode.
+func fileID(info fs.FileInfo) (device, inode uint64, ok bool) {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return 0, 0, false
+	}
+	return uint64(stat.Dev), uint64(stat.Ino), true
+}
@@ -0,0 +1,185 @@
+package grep
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// Encoding is the character encoding of the files searched. Files in other
+// encodings than UTF-8 are converted to UTF-8 before matching, so the stored
+// Line.Text and the Spans in it are in UTF-8.
+type Encoding int
+
+const (
+	// EncodingAuto reads files starting with a byte order mark as UTF-8,
+	// UTF-16LE or UTF-16BE, as the mark says, and the others as UTF-8. It is
+	// the default.
+	EncodingAuto Encoding = iota
+	EncodingUTF8
+	EncodingUTF16LE
+	EncodingUTF16BE
+	// EncodingLatin1 is ISO 8859-1.
+	EncodingLatin1
+	EncodingWindows1252
+)
+
+var (
+	bomUTF8    = []byte{0xef, 0xbb, 0xbf}
+	bomUTF16LE = []byte{0xff, 0xfe}
+	bomUTF16BE = []byte{0xfe, 0xff}
+)
+
+// SetEncoding sets the encoding of the files searched. A byte order mark at
+// the start of a file is never matched. Binary search, see SetBinarySearch,
+// always matches the raw bytes.
+func (f *finder) SetEncoding(encoding Encoding) {
+	f.encoding = encoding
+}
+
+// decode returns a reader of r converted to UTF-8, and decoded set, if r is
+// not UTF-8 or starts with a byte order mark. Otherwise it returns a reader
+// of r as is.
+func (f *finder) decode(r io.Reader) (_ io.Reader, decoded bool) {
+	if f.binarySearch {
+		return r, false
+	}
+	switch f.encoding {
+	case EncodingLatin1:
+		return &charmapReader{r: r}, true
+	case EncodingWindows1252:
+		return &charmapReader{r: r, table: &windows1252}, true
+	}
+
+	br, magic := sniffMagic(r, len(bomUTF16LE), bomUTF8)
+	encoding := f.encoding
+	switch {
+	case bytes.HasPrefix(magic, bomUTF8) && (encoding == EncodingAuto || encoding == EncodingUTF8):
+		br.Discard(len(bomUTF8))
+		return br, true
+	case bytes.HasPrefix(magic, bomUTF16LE) && (encoding == EncodingAuto || encoding == EncodingUTF16LE):
+		br.Discard(len(bomUTF16LE))
+		encoding = EncodingUTF16LE
+	case bytes.HasPrefix(magic, bomUTF16BE) && (encoding == EncodingAuto || encoding == EncodingUTF16BE):
+		br.Discard(len(bomUTF16BE))
+		encoding = EncodingUTF16BE
+	}
+	switch encoding {
+	case EncodingUTF16LE, EncodingUTF16BE:
+		return &utf16Reader{r: br, bigEndian: encoding == EncodingUTF16BE}, true
+	}
+	return br, false
+}
+
+// utf16Reader converts UTF-16 read from r to UTF-8. Invalid code units are
+// replaced with utf8.RuneError.
+type utf16Reader struct {
+	r         *bufio.Reader
+	bigEndian bool
+	// pending holds converted bytes that didn't fit into the last Read.
+	pending []byte
+	// high is a high surrogate waiting for its pair, or 0.
+	high rune
+}
+
+func (u *utf16Reader) Read(p []byte) (int, error) {
+	var unit [2]byte
+	for len(u.pending) < len(p) {
+		n, err := io.ReadFull(u.r, unit[:])
+		if n == 1 {
+			// A dangling byte at the end.
+			u.flushHigh()
+			u.pending = utf8.AppendRune(u.pending, utf8.RuneError)
+		}
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			u.flushHigh()
+			if len(u.pending) == 0 {
+				return 0, io.EOF
+			}
+			break
+		}
+		if err != nil {
+			return 0, err
+		}
+
+		r := rune(unit[0]) | rune(unit[1])<<8
+		if u.bigEndian {
+			r = rune(unit[0])<<8 | rune(unit[1])
+		}
+		switch {
+		case u.high != 0 && 0xdc00 <= r && r < 0xe000:
+			u.pending = utf8.AppendRune(u.pending, utf16.DecodeRune(u.high, r))
+			u.high = 0
+		case 0xd800 <= r && r < 0xdc00:
+			u.flushHigh()
+			u.high = r
+		default:
+			u.flushHigh()
+			// utf8 replaces an unpaired low surrogate with RuneError.
+			u.pending = utf8.AppendRune(u.pending, r)
+		}
+		if u.r.Buffered() == 0 && len(u.pending) > 0 {
+			// Don't block a stream for more input.
+			break
+		}
+	}
+	n := copy(p, u.pending)
+	u.pending = u.pending[:copy(u.pending, u.pending[n:])]
+	return n, nil
+}
+
+// flushHigh replaces a high surrogate without its pair with RuneError.
+func (u *utf16Reader) flushHigh() {
+	if u.high != 0 {
+		u.pending = utf8.AppendRune(u.pending, utf8.RuneError)
+		u.high = 0
+	}
+}
+
+// charmapReader converts a single-byte encoding read from r to UTF-8. The
+// bytes from 0x80 to 0x9f are looked up in table, or kept as the code points
+// of the same value, as in Latin-1, if table is nil.
+type charmapReader struct {
+	r       io.Reader
+	table   *[32]rune
+	buf     []byte
+	pending []byte
+	// err is the error of the last read from r, returned once pending is
+	// drained.
+	err error
+}
+
+func (c *charmapReader) Read(p []byte) (int, error) {
+	if len(c.pending) == 0 && c.err == nil {
+		if len(c.buf) < len(p) {
+			c.buf = make([]byte, len(p))
+		}
+		var n int
+		n, c.err = c.r.Read(c.buf[:len(p)])
+		for _, b := range c.buf[:n] {
+			switch {
+			case b < utf8.RuneSelf:
+				c.pending = append(c.pending, b)
+			case b < 0xa0 && c.table != nil:
+				c.pending = utf8.AppendRune(c.pending, c.table[b-0x80])
+			default:
+				c.pending = utf8.AppendRune(c.pending, rune(b))
+			}
+		}
+	}
+	if len(c.pending) == 0 {
+		return 0, c.err
+	}
+	n := copy(p, c.pending)
+	c.pending = c.pending[:copy(c.pending, c.pending[n:])]
+	return n, nil
+}
+
+// windows1252 maps the bytes from 0x80 to 0x9f of Windows-1252. The bytes it
+// leaves undefined are kept as the control characters of the same value.
+var windows1252 = [32]rune{
+	'€', 0x81, '‚', 'ƒ', '„', '…', '†', '‡', 'ˆ', '‰', 'Š', '‹', 'Œ', 0x8d, 'Ž', 0x8f,
+	0x90, '‘', '’', '“', '”', '•', '–', '—', '˜', '™', 'š', '›', 'œ', 0x9d, 'ž', 'Ÿ',
+}
@@ -0,0 +1,140 @@
+//go:build !time
+
+package grep_test
+
+import (
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+	"unicode/utf16"
+
+	"github.com/alex123012/go-grep"
+)
+
+func encodeUTF16(text string, bigEndian bool) string {
+	var result []byte
+	for _, unit := range utf16.Encode([]rune(text)) {
+		if bigEndian {
+			result = append(result, byte(unit>>8), byte(unit))
+		} else {
+			result = append(result, byte(unit), byte(unit>>8))
+		}
+	}
+	return string(result)
+}
+
+func TestEncodingBOM(t *testing.T) {
+	const text = "Windows Registry Editor Version 5.00\r\n\r\n[HKEY_CURRENT_USER\\Software\\Café 🚀]\r\n\"Path\"=\"C:\\\\kill\"\r\n"
+	files := map[string]string{
+		"utf8.reg":    "\xef\xbb\xbf" + text,
+		"utf16le.reg": "\xff\xfe" + encodeUTF16(text, false),
+		"utf16be.reg": "\xfe\xff" + encodeUTF16(text, true),
+	}
+	expected := map[int]grep.Line{
+		3: {Number: 3, Text: "[HKEY_CURRENT_USER\\Software\\Café 🚀]", Column: 29, Spans: []grep.Span{{Start: 28, End: 33}}},
+	}
+	for name, content := range files {
+		fileName := writeTestFile(t, name, content)
+		for _, threshold := range []int64{0, 1} {
+			patternSearch := grep.MakeStringFinder("Café")
+			patternSearch.SetMmapThreshold(threshold)
+			fileMap, err := patternSearch.Search(fileName, false)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if result := searchResults(fileMap)[fileName]; !reflect.DeepEqual(result, expected) {
+				t.Fatalf("Expected %+v in %s, but got %+v", expected, name, result)
+			}
+		}
+
+		// The byte order mark isn't part of the first line.
+		patternSearch, err := grep.MakeRegexpFinder("^Windows")
+		if err != nil {
+			t.Fatal(err)
+		}
+		fileMap, err := patternSearch.Search(fileName, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, f := searchResults(fileMap)[fileName][1]; !f {
+			t.Fatalf("Expected line 1 of %s to match, but got %+v", name, fileMap.GetStruct())
+		}
+	}
+}
+
+func TestEncodingExplicit(t *testing.T) {
+	fileName := writeTestFile(t, "legacy.txt", "first\ncaf\xe9 \x80 \x93quoted\x94\n")
+
+	patternSearch := grep.MakeStringFinder("café")
+	fileMap, err := patternSearch.Search(fileName, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v := fileMap.Len(); v != 0 {
+		t.Fatalf("Expected invalid UTF-8 to be binary, but got %+v", fileMap.GetStruct())
+	}
+
+	for encoding, text := range map[grep.Encoding]string{
+		grep.EncodingLatin1:      "café \u0080 \u0093quoted\u0094",
+		grep.EncodingWindows1252: "café € “quoted”",
+	} {
+		patternSearch.SetEncoding(encoding)
+		fileMap, err = patternSearch.Search(fileName, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if line := searchResults(fileMap)[fileName][2]; line.Text != text {
+			t.Fatalf("Expected %q with encoding %d, but got %+v", text, encoding, line)
+		}
+	}
+
+	patternSearch.SetEncoding(grep.EncodingUTF16LE)
+	utf16Name := writeTestFile(t, "no_bom.txt", encodeUTF16("one\ntwo café\n", false))
+	fileMap, err = patternSearch.Search(utf16Name, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if line := searchResults(fileMap)[utf16Name][2]; line.Text != "two café" {
+		t.Fatalf("Expected line 2 of UTF-16 without a byte order mark, but got %+v", line)
+	}
+}
+
+func TestEncodingReader(t *testing.T) {
+	content := "\xff\xfe" + encodeUTF16("a\nb kill\n"+strings.Repeat("x", 100000)+"kill\n", false)
+	fileMap, err := grep.MakeStringFinder("kill").SearchReader("(standard input)", strings.NewReader(content))
+	if err != nil {
+		t.Fatal(err)
+	}
+	result := searchResults(fileMap)["(standard input)"]
+	if len(result) != 2 || result[2].Column != 3 || result[3].Column != 100001 {
+		t.Fatalf("Expected lines 2 and 3, but got %+v", result)
+	}
+}
+
+// lastReadError returns all of data with err from its first Read, and io.EOF
+// after that, so err is seen only if it isn't dropped.
+type lastReadError struct {
+	data string
+	err  error
+	done bool
+}
+
+func (r *lastReadError) Read(p []byte) (int, error) {
+	if r.done {
+		return 0, io.EOF
+	}
+	r.done = true
+	return copy(p, r.data), r.err
+}
+
+func TestEncodingReadError(t *testing.T) {
+	readErr := errors.New("read failed")
+	patternSearch := grep.MakeStringFinder("café")
+	patternSearch.SetEncoding(grep.EncodingLatin1)
+	_, err := patternSearch.SearchReader("failing", &lastReadError{data: "caf\xe9\n", err: readErr})
+	if !errors.Is(err, readErr) {
+		t.Fatalf("Expected %v, but got %v", readErr, err)
+	}
+}
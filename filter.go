@@ -0,0 +1,223 @@
+package grep
+
+import (
+	"fmt"
+	"io/fs"
+	"path"
+)
+
+// SetGlobs limits Search to the files matching one of include, if there are
+// any, and skips the files and directories matching one of exclude. The
+// patterns are those of .gitignore files, without negation, relative to the
+// root of Search: "*_test.go" matches files at any depth, "testdata/**" what is
+// in the testdata directory at the root and "**/testdata/" every testdata
+// directory. A file given as the root is always searched.
+func (f *finder) SetGlobs(include, exclude []string) error {
+	includeRules, err := compileRules(include)
+	if err != nil {
+		return err
+	}
+	excludeRules, err := compileRules(exclude)
+	if err != nil {
+		return err
+	}
+	f.include, f.exclude = includeRules, excludeRules
+	return nil
+}
+
+func compileRules(patterns []string) ([]ignoreRule, error) {
+	var rules []ignoreRule
+	for _, pattern := range patterns {
+		rule, err := compileRule(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %q", path.ErrBadPattern, pattern)
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+// SetTypes limits Search to the files of one of the types include, if there
+// are any, and skips the files of the types exclude. The types are looked up
+// in the registry of AddFileType when SetTypes is called. A file is of a type
+// by its name or, failing that, by the shebang line at its start. A file given
+// as the root is always searched.
+func (f *finder) SetTypes(include, exclude []string) error {
+	includeTypes, err := lookupFileTypes(include)
+	if err != nil {
+		return err
+	}
+	excludeTypes, err := lookupFileTypes(exclude)
+	if err != nil {
+		return err
+	}
+	f.types, f.notTypes = includeTypes, excludeTypes
+	return nil
+}
+
+// walkFilter decides which of the files and directories walked by SearchFS
+// are searched.
+type walkFilter struct {
+	*finder
+	fsys fs.FS
+	// linkFS is fsys if it can read symbolic links.
+	linkFS  readLinkFS
+	root    string
+	ignorer *ignorer
+
+	// rootDevice is the device of the root, if hasRootDevice is set.
+	rootDevice    uint64
+	hasRootDevice bool
+
+	// visited holds the directories walked, by their latest path relative
+	// to the root, if symbolic links to directories are followed.
+	visited map[fileKey]string
+}
+
+func (f *finder) newWalkFilter(fsys fs.FS, root string) *walkFilter {
+	w := &walkFilter{finder: f, fsys: fsys, root: root, ignorer: f.newIgnorer(fsys, root), visited: map[fileKey]string{}}
+	w.linkFS, _ = fsys.(readLinkFS)
+	if f.oneFileSystem {
+		if info, err := fs.Stat(fsys, root); err == nil {
+			w.rootDevice, _, w.hasRootDevice = fileID(info)
+		}
+	}
+	return w
+}
+
+// walk searches the directory tree at dir and names what is in it as if dir
+// was at walked, which is dir itself unless dir is the target of a symbolic
+// link.
+func (w *walkFilter) walk(dir, walked string) error {
+	return fs.WalkDir(w.fsys, dir, func(open string, info fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		name := open
+		if dir != walked {
+			if rel := relName(dir, open); rel == "." {
+				name = walked
+			} else {
+				name = path.Join(walked, rel)
+			}
+		}
+
+		switch {
+		case info.IsDir():
+			if w.skipDir(open, name, info) {
+				return fs.SkipDir
+			}
+		case info.Type()&fs.ModeSymlink != 0 && w.linkFS != nil:
+			return w.followLink(open, name)
+		default:
+			w.match(open, name)
+		}
+		return nil
+	})
+}
+
+// match searches the file open, named name, unless it is filtered out.
+func (w *walkFilter) match(open, name string) {
+	search, byShebang := w.searchFile(name)
+	if !search {
+		return
+	}
+	w.errGroup.Go(func() error {
+		return w.patternMatch(w.fsys, open, name, byShebang)
+	})
+}
+
+// skipDir reports whether the directory open, named name, is skipped, and
+// otherwise enters it.
+func (w *walkFilter) skipDir(open, name string, entry fs.DirEntry) bool {
+	rel := relName(w.root, name)
+	if rel != "." {
+		// The files in a directory at the maximum depth would be too deep.
+		if w.maxDepth > 0 && depth(rel) >= w.maxDepth {
+			return true
+		}
+		if !w.hidden && hiddenName(rel) {
+			return true
+		}
+		if w.ignorer != nil && w.ignorer.ignored(rel, true) {
+			return true
+		}
+		if w.otherDevice(entry) {
+			return true
+		}
+		for _, rule := range w.exclude {
+			if rule.match(rel, true) || rule.glob.matchesAllIn(rel) {
+				return true
+			}
+		}
+	}
+	if w.symlinks == SymlinkAll && w.inLoop(open, name, entry) {
+		return true
+	}
+	if w.ignorer != nil {
+		w.ignorer.enter(open, rel)
+	}
+	return false
+}
+
+// searchFile reports whether the file name is searched. If that depends on
+// its shebang line, byShebang is returned to be called with the interpreter
+// named there.
+func (w *walkFilter) searchFile(name string) (search bool, byShebang func(interpreter string) bool) {
+	if name == w.root {
+		return true, nil
+	}
+	rel := relName(w.root, name)
+	if !w.hidden && hiddenName(rel) {
+		return false, nil
+	}
+	if w.ignorer != nil && w.ignorer.ignored(rel, false) {
+		return false, nil
+	}
+	for _, rule := range w.exclude {
+		if rule.match(rel, false) {
+			return false, nil
+		}
+	}
+	base := path.Base(rel)
+	for _, fileType := range w.notTypes {
+		if fileType.matchName(base) {
+			return false, nil
+		}
+	}
+
+	selected := len(w.include) == 0 && len(w.types) == 0
+	for _, rule := range w.include {
+		selected = selected || rule.match(rel, false)
+	}
+	for _, fileType := range w.types {
+		selected = selected || fileType.matchName(base)
+	}
+	if !selected && !hasInterpreters(w.types) {
+		return false, nil
+	}
+	if selected && !hasInterpreters(w.notTypes) {
+		return true, nil
+	}
+	return true, func(interpreter string) bool {
+		for _, fileType := range w.notTypes {
+			if fileType.matchInterpreter(interpreter) {
+				return false
+			}
+		}
+		matched := selected
+		for _, fileType := range w.types {
+			matched = matched || fileType.matchInterpreter(interpreter)
+		}
+		return matched
+	}
+}
+
+func hasInterpreters(types []FileType) bool {
+	for _, fileType := range types {
+		if len(fileType.Interpreters) > 0 {
+			return true
+		}
+	}
+	return false
+}
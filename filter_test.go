@@ -0,0 +1,123 @@
+//go:build !time
+
+package grep_test
+
+import (
+	"errors"
+	"path"
+	"reflect"
+	"testing"
+	"testing/fstest"
+
+	"github.com/alex123012/go-grep"
+)
+
+func TestGlobsAndTypes(t *testing.T) {
+	fsys := fstest.MapFS{
+		"main.go":                 {Data: []byte("kill")},
+		"main_test.go":            {Data: []byte("kill")},
+		"go.mod":                  {Data: []byte("kill")},
+		"api/api.proto":           {Data: []byte("kill")},
+		"api/api.pb.go":           {Data: []byte("kill")},
+		"deploy/app.yaml":         {Data: []byte("kill")},
+		"testdata/input.go":       {Data: []byte("kill")},
+		"pkg/testdata/deep/x.txt": {Data: []byte("kill")},
+		"scripts/run":             {Data: []byte("#!/usr/bin/env -S python3.11 -u\nkill")},
+		"scripts/build":           {Data: []byte("#!/bin/bash\nkill")},
+		"scripts/notes":           {Data: []byte("kill")},
+		"Makefile":                {Data: []byte("kill")},
+		"seqs/genome.fa":          {Data: []byte(">kill\nACGT")},
+	}
+	testCases := []struct {
+		include, exclude []string
+		types, notTypes  []string
+		expected         []string
+	}{
+		{
+			include:  []string{"*.go"},
+			exclude:  []string{"*_test.go", "testdata/**"},
+			expected: []string{"api/api.pb.go", "main.go"},
+		},
+		{
+			exclude:  []string{"**/testdata/", "scripts", "/api/*.go", "*.y?ml"},
+			expected: []string{"Makefile", "api/api.proto", "go.mod", "main.go", "main_test.go", "seqs/genome.fa"},
+		},
+		{
+			types:    []string{"go", "proto"},
+			notTypes: []string{"py"},
+			exclude:  []string{"**/*_test.go"},
+			expected: []string{"api/api.pb.go", "api/api.proto", "go.mod", "main.go", "testdata/input.go"},
+		},
+		{
+			types:    []string{"py", "sh", "fasta", "make"},
+			expected: []string{"Makefile", "scripts/build", "scripts/run", "seqs/genome.fa"},
+		},
+		{
+			include:  []string{"scripts/*"},
+			notTypes: []string{"sh"},
+			expected: []string{"scripts/notes", "scripts/run"},
+		},
+	}
+	for _, testCase := range testCases {
+		patternSearch := grep.MakeStringFinder("kill")
+		if err := patternSearch.SetGlobs(testCase.include, testCase.exclude); err != nil {
+			t.Fatal(err)
+		}
+		if err := patternSearch.SetTypes(testCase.types, testCase.notTypes); err != nil {
+			t.Fatal(err)
+		}
+		for _, root := range []string{".", "main.go"} {
+			fileMap, err := patternSearch.SearchFS(fsys, root, true)
+			if err != nil {
+				t.Fatal(err)
+			}
+			expected := testCase.expected
+			if root != "." {
+				// A file given as the root is always searched.
+				expected = []string{root}
+			}
+			if result := foundFiles(t, fileMap, "."); !reflect.DeepEqual(result, expected) {
+				t.Errorf("Expected %v for %+v in %s, but got %v", expected, testCase, root, result)
+			}
+		}
+	}
+}
+
+func TestFileTypeRegistry(t *testing.T) {
+	patternSearch := grep.MakeStringFinder("kill")
+	if err := patternSearch.SetTypes([]string{"go", "nosuchtype"}, nil); !errors.Is(err, grep.ErrUnknownFileType) {
+		t.Fatalf("Expected an unknown file type error, but got %v", err)
+	}
+	if err := patternSearch.SetGlobs([]string{"[a-"}, nil); !errors.Is(err, path.ErrBadPattern) {
+		t.Fatalf("Expected a bad pattern error, but got %v", err)
+	}
+
+	grep.AddFileType("bazel", grep.FileType{Extensions: []string{"bzl"}, Names: []string{"BUILD", "WORKSPACE"}})
+	grep.AddFileType("bazel", grep.FileType{Names: []string{"BUILD.bazel"}})
+	found := false
+	for _, name := range grep.FileTypes() {
+		found = found || name == "bazel"
+	}
+	if !found {
+		t.Fatalf("Expected bazel among the file types, but got %v", grep.FileTypes())
+	}
+
+	fsys := fstest.MapFS{
+		"BUILD":           {Data: []byte("kill")},
+		"x/BUILD.bazel":   {Data: []byte("kill")},
+		"x/defs.bzl":      {Data: []byte("kill")},
+		"x/BUILD.txt":     {Data: []byte("kill")},
+		"x/WORKSPACE.old": {Data: []byte("kill")},
+	}
+	if err := patternSearch.SetTypes([]string{"bazel"}, nil); err != nil {
+		t.Fatal(err)
+	}
+	fileMap, err := patternSearch.SearchFS(fsys, ".", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"BUILD", "x/BUILD.bazel", "x/defs.bzl"}
+	if result := foundFiles(t, fileMap, "."); !reflect.DeepEqual(result, expected) {
+		t.Errorf("Expected %v, but got %v", expected, result)
+	}
+}
@@ -0,0 +1,128 @@
+//go:build !time
+
+package grep_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/alex123012/go-grep"
+)
+
+type spanFinder interface {
+	FindAll(text []byte, overlapping bool) []grep.Span
+}
+
+func TestFindAll(t *testing.T) {
+	regexpFinder, err := grep.MakeRegexpFinder("a+")
+	if err != nil {
+		t.Fatal(err)
+	}
+	foldFinder := grep.MakeStringFinder("straße")
+	foldFinder.SetCaseMode(grep.CaseInsensitive)
+
+	testCases := []struct {
+		finder      spanFinder
+		text        string
+		overlapping bool
+		spans       []grep.Span
+	}{
+		{
+			finder: grep.MakeStringFinder("aa"),
+			text:   "aaaaa",
+			spans:  []grep.Span{{Start: 0, End: 2}, {Start: 2, End: 4}},
+		},
+		{
+			finder:      grep.MakeStringFinder("aa"),
+			text:        "aaaaa",
+			overlapping: true,
+			spans:       []grep.Span{{Start: 0, End: 2}, {Start: 1, End: 3}, {Start: 2, End: 4}, {Start: 3, End: 5}},
+		},
+		{
+			finder: grep.MakeStringFinder("aa"),
+			text:   "bbb",
+		},
+		{
+			finder: grep.MakeStringFinder(""),
+			text:   "",
+			spans:  []grep.Span{{Start: 0, End: 0}},
+		},
+		{
+			finder: grep.MakeStringFinder(""),
+			text:   "ab",
+			spans:  []grep.Span{{Start: 0, End: 0}, {Start: 1, End: 1}, {Start: 2, End: 2}},
+		},
+		{
+			finder: foldFinder,
+			text:   "x STRAẞE straße",
+			spans:  []grep.Span{{Start: 2, End: 10}, {Start: 11, End: 18}},
+		},
+		{
+			finder: regexpFinder,
+			text:   "baab a",
+			spans:  []grep.Span{{Start: 1, End: 3}, {Start: 5, End: 6}},
+		},
+		{
+			finder:      regexpFinder,
+			text:        "baab a",
+			overlapping: true,
+			spans:       []grep.Span{{Start: 1, End: 3}, {Start: 2, End: 3}, {Start: 5, End: 6}},
+		},
+		{
+			finder: grep.MakeMultiFinder([]string{"he", "she", "hers"}),
+			text:   "ushers",
+			spans:  []grep.Span{{Start: 1, End: 4}},
+		},
+		{
+			finder:      grep.MakeMultiFinder([]string{"he", "she", "hers"}),
+			text:        "ushers",
+			overlapping: true,
+			spans:       []grep.Span{{Start: 1, End: 4}, {Start: 2, End: 6}, {Start: 2, End: 4}},
+		},
+	}
+	for i, testCase := range testCases {
+		spans := testCase.finder.FindAll([]byte(testCase.text), testCase.overlapping)
+		if len(spans) != len(testCase.spans) || (len(spans) > 0 && !reflect.DeepEqual(spans, testCase.spans)) {
+			t.Fatalf("Test case %d: expected spans %v in %q, but got %v", i, testCase.spans, testCase.text, spans)
+		}
+	}
+}
+
+func TestEmptyPatternMatchesEmptyLines(t *testing.T) {
+	fileName := writeTestFile(t, "lines.txt", "a\n\nb\n")
+	fileMap, err := grep.MakeStringFinder("").Search(fileName, false)
+	if err != nil {
+		t.Fatalf("Error in executing test on %s: %v", fileName, err)
+	}
+	v, _ := fileMap.Get(fileName)
+	if len := v.(grep.SyncMap).Len(); len != 3 {
+		t.Fatalf("Expected all 3 lines, but got %d", len)
+	}
+	if line, _ := v.(grep.SyncMap).Get(2); line.(*grep.Line).Text != "" {
+		t.Fatalf("Expected empty line 2, but got %+v", line)
+	}
+}
+
+func TestSearchSpans(t *testing.T) {
+	fileName := writeTestFile(t, "spans.txt", "no match\nkill, kill and kill\n")
+	patternSearch := grep.MakeStringFinder("kill")
+	fileMap, err := patternSearch.Search(fileName, false)
+	if err != nil {
+		t.Fatalf("Error in executing test on %s: %v", fileName, err)
+	}
+
+	v, _ := fileMap.Get(fileName)
+	line, f := v.(grep.SyncMap).Get(2)
+	if !f {
+		t.Fatal("Expected line 2 to match")
+	}
+	expected := &grep.Line{
+		Number: 2,
+		Text:   "kill, kill and kill",
+		Column: 1,
+		Spans:  []grep.Span{{Start: 0, End: 4}, {Start: 6, End: 10}, {Start: 15, End: 19}},
+	}
+	if !reflect.DeepEqual(line, expected) {
+		t.Fatalf("Expected %+v, but got %+v", expected, line)
+	}
+}
@@ -6,13 +6,8 @@
 package grep
 
 import (
-	"bufio"
 	"bytes"
-	"os"
-	"path/filepath"
-
-	"golang.org/x/sync/errgroup"
-	"golang.org/x/tools/godoc/util"
+	"unicode/utf8"
 )
 
 // StringFinder efficiently finds strings in a source text. It's implemented
@@ -20,13 +15,23 @@ import (
 // https://en.wikipedia.org/wiki/Boyer-Moore_string_search_algorithm
 // https://www.cs.utexas.edu/~moore/publications/fstrpos.pdf (note: this aged
 // document uses 1-based indexing)
-
-const GouroutinesLimit = 512
-
 type StringFinder struct {
-	// pattern is the string that we are searching for in the text.
+	// source is the pattern as it was given by the caller.
+	source string
+
+	// pattern is the string that we are searching for in the text. It is
+	// folded to lower case for case-insensitive ASCII search.
 	pattern []byte
 
+	// fold selects the comparison used by search.
+	fold foldKind
+	// nonASCIIFolds is set for asciiFold patterns with letters that also
+	// fold to non-ASCII runes.
+	nonASCIIFolds bool
+
+	// boundary is checked for every occurrence of pattern found in the text.
+	boundary BoundaryMode
+
 	// badCharSkip[b] contains the distance between the last byte of pattern
 	// and the rightmost occurrence of b in pattern. If b is not in pattern,
 	// badCharSkip[b] is len(pattern).
@@ -60,21 +65,29 @@ type StringFinder struct {
 
 	patternLen int
 
-	mapMaker func() SyncMap
-
-	errGroup *errgroup.Group
-	mapFiles *MapFiles
+	*finder
 }
 
 func MakeStringFinder(pattern string) *StringFinder {
-	patternByte := []byte(pattern)
-	f := &StringFinder{
-		pattern:        patternByte,
-		patternLen:     len(pattern),
-		goodSuffixSkip: make([]int, len(patternByte)),
-		errGroup:       &errgroup.Group{},
-	}
-	f.errGroup.SetLimit(GouroutinesLimit)
+	f := makeStringSearcher(pattern)
+	f.finder = makeFinder(f)
+	return f
+}
+
+// makeStringSearcher builds only the Boyer-Moore tables for pattern, so other
+// finders can use it as a literal prefilter without their own errgroup.
+func makeStringSearcher(pattern string) *StringFinder {
+	f := &StringFinder{source: pattern}
+	f.buildTables([]byte(pattern))
+	return f
+}
+
+// buildTables fills the skip tables for patternByte, which is already folded
+// to lower case when f.fold is asciiFold.
+func (f *StringFinder) buildTables(patternByte []byte) {
+	f.pattern = patternByte
+	f.patternLen = len(patternByte)
+	f.goodSuffixSkip = make([]int, len(patternByte))
 	// last is the index of the last character in the pattern.
 	last := len(patternByte) - 1
 
@@ -88,6 +101,11 @@ func MakeStringFinder(pattern string) *StringFinder {
 	// that it is not in the last position.
 	for i := 0; i < last; i++ {
 		f.badCharSkip[patternByte[i]] = last - i
+		if f.fold == asciiFold {
+			// Text is not folded before lookup, so the upper case byte
+			// must skip the same distance.
+			f.badCharSkip[toUpperASCII(patternByte[i])] = last - i
+		}
 	}
 
 	// Build good suffix table.
@@ -109,14 +127,25 @@ func MakeStringFinder(pattern string) *StringFinder {
 			f.goodSuffixSkip[last-lenSuffix] = lenSuffix + last - i
 		}
 	}
+}
 
-	return f
+// search returns the index in text of the first occurrence of the pattern at
+// or after from. If the pattern is not found, it returns -1. The boundary is
+// checked against the bytes around the occurrence in the whole text, so a
+// search can go on after an earlier match.
+func (f *StringFinder) search(text []byte, from int) int {
+	return f.find(text, from, true)
 }
 
-// next returns the index in text of the first occurrence of the pattern. If
-// the pattern is not found, it returns -1.
-func (f *StringFinder) search(text []byte) int {
-	i := f.patternLen - 1
+// find is search, which checks the boundary only if bounded is set.
+func (f *StringFinder) find(text []byte, from int, bounded bool) int {
+	switch f.fold {
+	case asciiFold:
+		return f.findASCIIFold(text, from, bounded)
+	case unicodeFold:
+		return f.searchUnicodeFold(text, from, bounded)
+	}
+	i := from + f.patternLen - 1
 	for i < len(text) {
 		// Compare backwards from the end until the first unmatching character.
 		j := f.patternLen - 1
@@ -125,86 +154,68 @@ func (f *StringFinder) search(text []byte) int {
 			j--
 		}
 		if j < 0 {
-			return i + 1 // match
+			if !bounded || f.atBoundary(text, i+1, i+1+f.patternLen) {
+				return i + 1 // match
+			}
+			// Rejected by the boundary check, shift the frame by one.
+			i += f.patternLen + 1
+			continue
 		}
 		i += max(f.badCharSkip[text[i]], f.goodSuffixSkip[j])
 	}
 	return -1
 }
 
-func (f *StringFinder) putInMap(key string, value []byte, line int) {
-	alreadyPresent, found := f.mapFiles.Get(key)
-	if found {
-		alreadyPresent.(SyncMap).Put(line, value)
-	} else {
-		lineMapper := f.mapMaker()
-		lineMapper.Put(line, value)
-		f.mapFiles.Put(key, lineMapper)
-	}
+func (f *StringFinder) matchLine(line []byte, res *Line) bool {
+	res.Spans = f.FindAll(line, f.overlapping)
+	return len(res.Spans) > 0
 }
 
-func (f *StringFinder) patternMatch(file string) error {
-	openFile, err := os.Open(file)
-	if err != nil {
-		return err
+func (f *StringFinder) maxMatchLen() int {
+	switch {
+	case f.boundary != AnyBoundary:
+		// The boundary check needs the text around the match.
+		return 0
+	case f.fold == unicodeFold || f.nonASCIIFolds:
+		// A folded rune may be up to three times longer in UTF-8, like the
+		// Kelvin sign for k.
+		return 3 * f.patternLen
 	}
-	defer openFile.Close()
-	scanner := bufio.NewScanner(openFile)
+	return f.patternLen
+}
 
-	i := 1
-	for scanner.Scan() {
-		if i == 1 && !util.IsText(scanner.Bytes()) {
-			return nil
+// FindAll returns the spans of all matches in text. With overlapping set a
+// match may start inside the previous one.
+func (f *StringFinder) FindAll(text []byte, overlapping bool) []Span {
+	var spans []Span
+	for pos := 0; pos <= len(text); {
+		start := f.search(text, pos)
+		if start == -1 {
+			break
+		}
+		end := start + f.matchLen(text[start:])
+		spans = append(spans, Span{Start: start, End: end})
+		if start == len(text) {
+			// An empty pattern matches once at the end.
+			break
 		}
-		if value := f.search(scanner.Bytes()); value != -1 {
-			f.putInMap(file, scanner.Bytes(), i)
+		if overlapping || end == start {
+			_, size := utf8.DecodeRune(text[start:])
+			pos = start + size
+		} else {
+			pos = end
 		}
-		i++
-	}
-	if err := scanner.Err(); err != nil {
-		return err
 	}
-	return nil
+	return spans
 }
 
-func (f *StringFinder) SetGouroutinesLimit(limit int) {
-	f.errGroup.SetLimit(limit)
-}
-func (f *StringFinder) Search(path string, onlyFiles bool) (*MapFiles, error) {
-	f.mapFiles = MakeMapFiles()
-	if onlyFiles {
-		f.mapMaker = MakeOnlyFiles
-	} else {
-		f.mapMaker = MakeLinesWithText
-	}
-	err := filepath.WalkDir(path,
-		func(path string, info os.DirEntry, err error) error {
-			if err != nil {
-				return err
-			}
-			if info.IsDir() {
-				return nil
-			}
-
-			if info.Type() == os.ModeSymlink {
-				sympath, err := os.Readlink(path)
-
-				if err != nil {
-					return err
-				}
-
-				path = filepath.Join(filepath.Dir(path), sympath)
-			}
-			f.errGroup.Go(func() error {
-				return f.patternMatch(path)
-			})
-
-			return nil
-		})
-	if err != nil {
-		return nil, err
+// matchLen returns the length of the match at the start of text, which
+// differs from the pattern length only for Unicode case folding.
+func (f *StringFinder) matchLen(text []byte) int {
+	if f.fold == unicodeFold || f.nonASCIIFolds {
+		return prefixFoldLen(text, f.pattern)
 	}
-	return f.mapFiles, f.errGroup.Wait()
+	return f.patternLen
 }
 
 func longestCommonSuffix(a, b []byte) (i int) {
@@ -3,10 +3,9 @@
 package grep_test
 
 import (
-	"bufio"
-	"errors"
 	"os"
 	"runtime"
+	"strings"
 	"testing"
 
 	"github.com/alex123012/go-grep"
@@ -63,10 +62,13 @@ func TestBrokenSymlink(t *testing.T) {
 		pattern:  "anything",
 	}
 	patternSearch := grep.MakeStringFinder(testCase.pattern)
-	_, err := patternSearch.Search(testCase.fileName, true)
+	fileMap, err := patternSearch.Search(testCase.fileName, true)
+	if err != nil {
+		t.Fatalf("Error in executing test on %s: %v", testCase.fileName, err)
+	}
 
-	if !os.IsNotExist(err) {
-		t.Errorf("Error in executing test on %s: %v", testCase.fileName, err)
+	if err := fileMap.Errors()[testCase.fileName]; !os.IsNotExist(err) {
+		t.Errorf("Expected a broken link error for %s, but got %v", testCase.fileName, err)
 	}
 }
 
@@ -83,14 +85,111 @@ func TestSymlink(t *testing.T) {
 
 func TestLongLine(t *testing.T) {
 	testCase := TestCase{
-		pattern:  "kill",
-		fileName: "./test_cases/test_long_lines.txt",
+		pattern:      "AAAAAElFTkSuQmCC",
+		fileName:     "./test_cases/test_long_lines.txt",
+		grepCount:    2,
+		grepLastLine: 2,
+	}
+	fileMap := testFile(testCase, t)
+	v, f := fileMap.Get(testCase.fileName)
+	if len := v.(grep.SyncMap).Len(); len != testCase.grepCount || !f {
+		t.Fatalf("Expected %d in StringFinder.Search, but got %d", testCase.grepCount, len)
 	}
-	patternSearch := grep.MakeStringFinder(testCase.pattern)
-	_, err := patternSearch.Search(testCase.fileName, true)
 
-	if !errors.Is(err, bufio.ErrTooLong) {
-		t.Errorf("Error in executing test on %s: %v", testCase.fileName, err)
+	testCase.onlyFiles = true
+	fileMap = testFile(testCase, t)
+	v, f = fileMap.Get(testCase.fileName)
+	if v, fv := v.(grep.SyncMap).Get(testCase.grepLastLine); v != testCase.grepLastLine || !f || !fv {
+		t.Fatalf("Expected %d in StringFinder.Search, but got %d", testCase.grepLastLine, v)
+	}
+}
+
+func TestLongLineText(t *testing.T) {
+	longLine := strings.Repeat("x", 200000) + "kill" + strings.Repeat("y", 100000)
+	fileName := writeTestFile(t, "long.txt", "kill\n"+longLine+"\r\nkill\n")
+
+	regexpFinder, err := grep.MakeRegexpFinder("ki+ll")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, maxLineText := range []int{0, 100} {
+		for name, patternSearch := range map[string]interface {
+			Search(path string, onlyFiles bool) (*grep.MapFiles, error)
+			SetMaxLineText(max int)
+		}{
+			"string": grep.MakeStringFinder("kill"),
+			"multi":  grep.MakeMultiFinder([]string{"kill", "k"}),
+			"regexp": regexpFinder,
+		} {
+			patternSearch.SetMaxLineText(maxLineText)
+			fileMap, err := patternSearch.Search(fileName, false)
+			if err != nil {
+				t.Fatalf("Error in executing test on %s: %v", fileName, err)
+			}
+			v, _ := fileMap.Get(fileName)
+			if len := v.(grep.SyncMap).Len(); len != 3 {
+				t.Fatalf("%s: expected 3 lines, but got %d", name, len)
+			}
+
+			value, _ := v.(grep.SyncMap).Get(2)
+			line := value.(*grep.Line)
+			expectedText := longLine
+			if maxLineText > 0 {
+				expectedText = longLine[:maxLineText]
+			}
+			if line.Text != expectedText || line.Column != 200001 || len(line.Spans) == 0 || line.Spans[0].End != 200004 {
+				t.Fatalf("%s: unexpected line 2 with %d bytes of text, column %d and spans %v",
+					name, len(line.Text), line.Column, line.Spans)
+			}
+			if _, f := v.(grep.SyncMap).Get(3); !f {
+				t.Fatalf("%s: expected line 3 after the long line", name)
+			}
+		}
+	}
+}
+
+func TestLongLineSegments(t *testing.T) {
+	// The line is longer than the 64 KiB buffer it is read with.
+	fileName := writeTestFile(t, "long.txt", strings.Repeat("a", 200001)+"\n")
+	for _, overlapping := range []bool{false, true} {
+		patternSearch := grep.MakeStringFinder("aa")
+		patternSearch.SetOverlapping(overlapping)
+		fileMap, err := patternSearch.Search(fileName, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		v, _ := fileMap.Get(fileName)
+		value, _ := v.(grep.SyncMap).Get(1)
+		spans := value.(*grep.Line).Spans
+		step, count := 2, 100000
+		if overlapping {
+			step, count = 1, 200000
+		}
+		if len(spans) != count {
+			t.Fatalf("Expected %d spans with overlapping %t, but got %d", count, overlapping, len(spans))
+		}
+		for i, span := range spans {
+			if span.Start != i*step || span.End != i*step+2 {
+				t.Fatalf("Expected span %d to start at %d, but got %v", i, i*step, span)
+			}
+		}
+	}
+
+	// The segments of a 1-byte pattern don't overlap, and the carriage
+	// return is at the end of the first one.
+	line := "b" + strings.Repeat("x", 64*1024-2)
+	fileName = writeTestFile(t, "crlf.txt", line+"\r\nb\n")
+	fileMap, err := grep.MakeStringFinder("b").Search(fileName, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	v, _ := fileMap.Get(fileName)
+	value, f := v.(grep.SyncMap).Get(1)
+	if !f || value.(*grep.Line).Text != line {
+		t.Fatalf("Expected line 1 without the carriage return, but got %+v", value)
+	}
+	if _, f := v.(grep.SyncMap).Get(2); !f {
+		t.Fatal("Expected line 2 after the long line")
 	}
 }
 
@@ -175,7 +274,7 @@ func testLimitGouroutines(limit int, testCase TestCase, t *testing.T) int {
 	go func() {
 		_, err := patternSearch.Search(testCase.fileName, false)
 
-		if err != nil && !errors.Is(err, bufio.ErrTooLong) {
+		if err != nil {
 			t.Errorf("Error in executing test on %s: %v", testCase.fileName, err)
 		}
 		close(closeChan)
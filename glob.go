@@ -0,0 +1,65 @@
+package grep
+
+import (
+	"path"
+	"strings"
+)
+
+// glob is a slash separated pattern, split into its segments. A "**" segment
+// matches any number of directories, and the others are matched with
+// path.Match, which also takes "[!...]" for "[^...]".
+type glob []string
+
+// compileGlob splits pattern into a glob and checks its syntax.
+func compileGlob(pattern string) (glob, error) {
+	segments := strings.Split(pattern, "/")
+	for i, segment := range segments {
+		if segment == "**" {
+			continue
+		}
+		segment = strings.ReplaceAll(segment, "[!", "[^")
+		if _, err := path.Match(segment, ""); err != nil {
+			return nil, err
+		}
+		segments[i] = segment
+	}
+	return segments, nil
+}
+
+// match reports whether name, a slash separated path, matches the whole glob.
+func (g glob) match(name string) bool {
+	return matchSegments(g, strings.Split(name, "/"))
+}
+
+// matchSegments matches the segments of a name against the pattern ones. A
+// trailing "**" needs at least one segment, so "dir/**" matches what is in
+// dir but not dir itself.
+func matchSegments(pattern, name []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			if len(pattern) == 1 {
+				return len(name) > 0
+			}
+			for i := 0; i <= len(name); i++ {
+				if matchSegments(pattern[1:], name[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(name) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(pattern[0], name[0]); !ok {
+			return false
+		}
+		pattern, name = pattern[1:], name[1:]
+	}
+	return len(name) == 0
+}
+
+// matchesAllIn reports whether the glob matches everything in the directory
+// dir, as "dir/**" does.
+func (g glob) matchesAllIn(dir string) bool {
+	return len(g) > 1 && g[len(g)-1] == "**" && matchSegments(g[:len(g)-1], strings.Split(dir, "/"))
+}
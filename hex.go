@@ -0,0 +1,245 @@
+package grep
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// ErrHexSyntax is returned by MakeHexFinder for malformed patterns.
+var ErrHexSyntax = errors.New("hex pattern syntax error")
+
+// BytesFinder finds byte sequences in which some bytes may be wildcards. It is
+// meant for binary files, see SetBinarySearch.
+type BytesFinder struct {
+	pattern []byte
+	// wildcard[i] is set when pattern[i] matches any byte.
+	wildcard []bool
+
+	// anchor searches the longest run of pattern without wildcards, which
+	// starts at anchorStart. With no wildcards it is the whole pattern.
+	anchor      *StringFinder
+	anchorStart int
+
+	*finder
+}
+
+// MakeBytesFinder builds a finder for the exact bytes of pattern.
+func MakeBytesFinder(pattern []byte) *BytesFinder {
+	return makeBytesFinder(pattern, make([]bool, len(pattern)))
+}
+
+// MakeHexFinder builds a finder for a pattern of hex bytes, where ?? matches
+// any single byte, for example "DE AD ?? EF". Spaces between bytes are
+// optional.
+func MakeHexFinder(hex string) (*BytesFinder, error) {
+	hex = strings.Join(strings.Fields(hex), "")
+	if len(hex)%2 != 0 {
+		return nil, fmt.Errorf("%w: odd number of digits in %q", ErrHexSyntax, hex)
+	}
+
+	pattern := make([]byte, len(hex)/2)
+	wildcard := make([]bool, len(hex)/2)
+	for i := range pattern {
+		digits := hex[2*i : 2*i+2]
+		if digits == "??" {
+			wildcard[i] = true
+			continue
+		}
+		high, okHigh := fromHexChar(digits[0])
+		low, okLow := fromHexChar(digits[1])
+		if !okHigh || !okLow {
+			return nil, fmt.Errorf("%w: invalid byte %q", ErrHexSyntax, digits)
+		}
+		pattern[i] = high<<4 | low
+	}
+	return makeBytesFinder(pattern, wildcard), nil
+}
+
+func makeBytesFinder(pattern []byte, wildcard []bool) *BytesFinder {
+	f := &BytesFinder{
+		pattern:  pattern,
+		wildcard: wildcard,
+	}
+	// Find the longest run without wildcards for Boyer-Moore.
+	anchorLen := 0
+	for start := 0; start < len(pattern); {
+		if wildcard[start] {
+			start++
+			continue
+		}
+		end := start
+		for end < len(pattern) && !wildcard[end] {
+			end++
+		}
+		if end-start > anchorLen {
+			f.anchorStart, anchorLen = start, end-start
+		}
+		start = end
+	}
+	if anchorLen > 0 {
+		f.anchor = makeStringSearcher(string(pattern[f.anchorStart : f.anchorStart+anchorLen]))
+	}
+	f.finder = makeFinder(f)
+	return f
+}
+
+func (f *BytesFinder) matchLine(line []byte, res *Line) bool {
+	res.Spans = f.FindAll(line, f.overlapping)
+	return len(res.Spans) > 0
+}
+
+func (f *BytesFinder) maxMatchLen() int {
+	return len(f.pattern)
+}
+
+// FindAll returns the spans of all occurrences of the pattern in text.
+func (f *BytesFinder) FindAll(text []byte, overlapping bool) []Span {
+	if len(f.pattern) == 0 {
+		return nil
+	}
+	var spans []Span
+	for pos := 0; pos+len(f.pattern) <= len(text); {
+		start := f.index(text, pos)
+		if start == -1 {
+			break
+		}
+		spans = append(spans, Span{Start: start, End: start + len(f.pattern)})
+		if overlapping {
+			pos = start + 1
+		} else {
+			pos = start + len(f.pattern)
+		}
+	}
+	return spans
+}
+
+// index returns the first occurrence of the pattern in text at or after pos,
+// or -1.
+func (f *BytesFinder) index(text []byte, pos int) int {
+	for pos+len(f.pattern) <= len(text) {
+		if f.anchor == nil {
+			// The pattern is all wildcards.
+			return pos
+		}
+		// The anchor can't start before anchorStart, or the pattern would
+		// begin before pos.
+		i := f.anchor.search(text, pos+f.anchorStart)
+		if i == -1 {
+			return -1
+		}
+		start := i - f.anchorStart
+		if start+len(f.pattern) > len(text) {
+			return -1
+		}
+		if f.matchAt(text[start:]) {
+			return start
+		}
+		pos = start + 1
+	}
+	return -1
+}
+
+func (f *BytesFinder) matchAt(text []byte) bool {
+	for i, b := range f.pattern {
+		if !f.wildcard[i] && text[i] != b {
+			return false
+		}
+	}
+	return true
+}
+
+// SetBinarySearch makes Search read files as raw bytes, without checking that
+// they are text, and report matches by byte offset instead of line number. A
+// stored Line then holds the matched bytes in Text and their offset in
+// Offset, and is stored under the number of the match, counting from 1.
+// Invert applies only to whole files in this mode. Finders without FindAll,
+// like Query, always search line by line.
+func (f *finder) SetBinarySearch(binary bool) {
+	f.binarySearch = binary
+}
+
+// binaryMatch stores the matches of finder in buf or, if r isn't nil, in what
+// is read from r. A finder whose matches have a bounded length reads r in
+// chunks that overlap by one byte less than the longest match; others read it
+// whole.
+func (f *finder) binaryMatch(r io.Reader, buf []byte, finder spanFinder, res *fileResult) error {
+	overlap := f.overlap(finder)
+	if r != nil && (overlap < 0 || overlap >= chunkSize/2) {
+		var err error
+		if buf, err = io.ReadAll(r); err != nil {
+			return err
+		}
+		r = nil
+	}
+
+	found := false
+	number := 0
+	// seen is the end of the chunks searched and end that of the last match.
+	seen, end := 0, 0
+	match := func(chunk []byte, offset int) bool {
+		from := 0
+		if !f.overlapping && end > offset {
+			// The next match mustn't overlap the last one.
+			from = min(end-offset, len(chunk))
+		}
+		for _, span := range finder.FindAll(chunk[from:], f.overlapping) {
+			span.Start += from
+			span.End += from
+			if offset+span.End <= seen {
+				// Found in the previous chunk.
+				continue
+			}
+			found = true
+			if f.invert {
+				return false
+			}
+			number++
+			res.put(number, &Line{
+				Offset: int64(offset + span.Start),
+				Text:   string(chunk[span.Start:span.End]),
+				Spans:  []Span{{Start: 0, End: span.End - span.Start}},
+			})
+			end = offset + span.End
+		}
+		seen = offset + len(chunk)
+		return res.err == nil
+	}
+
+	if r == nil {
+		match(buf, 0)
+	} else {
+		chunk := make([]byte, chunkSize)
+		n, offset := 0, 0
+		for {
+			read, err := io.ReadFull(r, chunk[n:])
+			n += read
+			eof := err == io.EOF || err == io.ErrUnexpectedEOF
+			if err != nil && !eof {
+				return err
+			}
+			if !match(chunk[:n], offset) || eof {
+				break
+			}
+			offset += n - overlap
+			n = copy(chunk, chunk[n-overlap:n])
+		}
+	}
+	if f.invert {
+		res.selected = !found
+	}
+	return nil
+}
+
+func fromHexChar(c byte) (byte, bool) {
+	switch {
+	case '0' <= c && c <= '9':
+		return c - '0', true
+	case 'a' <= c && c <= 'f':
+		return c - 'a' + 10, true
+	case 'A' <= c && c <= 'F':
+		return c - 'A' + 10, true
+	}
+	return 0, false
+}
@@ -0,0 +1,137 @@
+//go:build !time
+
+package grep_test
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/alex123012/go-grep"
+)
+
+const firmware = "\x7fELF\x02\x01\x00\xde\xad\xbe\xef\x00\xde\xad\x00\xef\xde\xad\xbe"
+
+func TestHexFinder(t *testing.T) {
+	fileName := writeTestFile(t, "firmware.bin", firmware)
+	testCases := []struct {
+		pattern string
+		offsets []int
+	}{
+		{pattern: "DE AD ?? EF", offsets: []int{7, 12}},
+		{pattern: "dead", offsets: []int{7, 12, 16}},
+		{pattern: "7F 45 4C 46", offsets: []int{0}},
+		{pattern: "?? AD", offsets: []int{7, 12, 16}},
+		{pattern: "DEAD BEEF 00", offsets: []int{7}},
+		{pattern: "CAFE", offsets: nil},
+	}
+	for _, testCase := range testCases {
+		patternSearch, err := grep.MakeHexFinder(testCase.pattern)
+		if err != nil {
+			t.Fatalf("Error in parsing %q: %v", testCase.pattern, err)
+		}
+		patternSearch.SetBinarySearch(true)
+		fileMap, err := patternSearch.Search(fileName, false)
+		if err != nil {
+			t.Fatalf("Error in executing test on %s: %v", fileName, err)
+		}
+
+		v, f := fileMap.Get(fileName)
+		if !f {
+			if len(testCase.offsets) > 0 {
+				t.Fatalf("Expected offsets %v for %q, but got none", testCase.offsets, testCase.pattern)
+			}
+			continue
+		}
+		lines := v.(grep.SyncMap)
+		if lines.Len() != len(testCase.offsets) {
+			t.Fatalf("Expected offsets %v for %q, but got %+v", testCase.offsets, testCase.pattern, fileMap.GetStruct())
+		}
+		for i, offset := range testCase.offsets {
+			line, f := lines.Get(i + 1)
+			if !f || line.(*grep.Line).Offset != int64(offset) || line.(*grep.Line).Number != 0 {
+				t.Fatalf("Expected a match at offset %d for %q, but got %+v", offset, testCase.pattern, line)
+			}
+		}
+	}
+}
+
+func TestHexFinderOnlyFiles(t *testing.T) {
+	fileName := writeTestFile(t, "firmware.bin", firmware)
+	patternSearch, err := grep.MakeHexFinder("7F 45 4C 46")
+	if err != nil {
+		t.Fatal(err)
+	}
+	patternSearch.SetBinarySearch(true)
+	fileMap, err := patternSearch.Search(fileName, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v, f := fileMap.Get(fileName); !f || v.(grep.SyncMap).Len() != 1 {
+		t.Fatalf("Expected the match at offset 0, but got %+v", fileMap.GetStruct())
+	}
+}
+
+func TestHexFinderChunks(t *testing.T) {
+	// The matches cross the 64 KiB chunks the stream is read in.
+	data := bytes.Repeat([]byte{0}, 3*64*1024)
+	offsets := []int{10, 64*1024 - 1, 2*64*1024 - 2}
+	for _, offset := range offsets {
+		copy(data[offset:], "\xde\xad\xbe")
+	}
+	patternSearch := grep.MakeBytesFinder([]byte("\xde\xad\xbe"))
+	patternSearch.SetBinarySearch(true)
+	fileMap, err := patternSearch.SearchReader("stream", bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var result []int
+	for _, file := range fileMap.GetStruct() {
+		for _, line := range file.Lines {
+			result = append(result, int(line.Offset))
+		}
+	}
+	sort.Ints(result)
+	if !reflect.DeepEqual(result, offsets) {
+		t.Fatalf("Expected offsets %v, but got %v", offsets, result)
+	}
+
+	patternSearch = grep.MakeBytesFinder([]byte("aa"))
+	patternSearch.SetBinarySearch(true)
+	fileMap, err = patternSearch.SearchReader("stream", bytes.NewReader(bytes.Repeat([]byte("a"), 200001)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	v, _ := fileMap.Get("stream")
+	lines := v.(grep.SyncMap)
+	if lines.Len() != 100000 {
+		t.Fatalf("Expected 100000 matches that don't overlap, but got %d", lines.Len())
+	}
+	for i := 0; i < lines.Len(); i++ {
+		if line, _ := lines.Get(i + 1); line.(*grep.Line).Offset != int64(2*i) {
+			t.Fatalf("Expected match %d at offset %d, but got %+v", i+1, 2*i, line)
+		}
+	}
+}
+
+func TestHexFinderText(t *testing.T) {
+	fileName := writeTestFile(t, "firmware.bin", firmware)
+	patternSearch := grep.MakeBytesFinder([]byte("\xde\xad"))
+	fileMap, err := patternSearch.Search(fileName, false)
+	if err != nil {
+		t.Fatalf("Error in executing test on %s: %v", fileName, err)
+	}
+	if v := fileMap.Len(); v != 0 {
+		t.Fatalf("Expected binary file to be skipped without binary search, but got %d files", v)
+	}
+}
+
+func TestHexSyntax(t *testing.T) {
+	for _, pattern := range []string{"DE A", "DE AG", "?A"} {
+		if _, err := grep.MakeHexFinder(pattern); !errors.Is(err, grep.ErrHexSyntax) {
+			t.Fatalf("Expected syntax error for %q, but got %v", pattern, err)
+		}
+	}
+}
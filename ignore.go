@@ -0,0 +1,356 @@
+package grep
+
+import (
+	"bufio"
+	"bytes"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// IgnoreSource is a set of the files whose gitignore patterns tell Search
+// which files and directories to skip.
+type IgnoreSource int
+
+const (
+	// IgnoreGit is the .gitignore files in git repositories.
+	IgnoreGit IgnoreSource = 1 << iota
+	// IgnoreDot is the .ignore files, which are used outside of git
+	// repositories too and take precedence over .gitignore files.
+	IgnoreDot
+	// IgnoreGitExclude is the .git/info/exclude file of a repository.
+	IgnoreGitExclude
+	// IgnoreGlobal is the file set as core.excludesFile in the global git
+	// configuration, or $XDG_CONFIG_HOME/git/ignore, which is used in every
+	// git repository.
+	IgnoreGlobal
+
+	// IgnoreAll is every source. It is the default.
+	IgnoreAll = IgnoreGit | IgnoreDot | IgnoreGitExclude | IgnoreGlobal
+)
+
+// SetIgnore sets the sources of the patterns of files and directories that
+// Search skips, as git does. Zero searches everything; otherwise .git
+// directories are skipped too. Ignore files in the directories above the root
+// of Search, up to the top of its repository, apply as well, but a file given
+// as the root is always searched.
+func (f *finder) SetIgnore(sources IgnoreSource) {
+	f.ignore = sources
+}
+
+// ignoreRule is one pattern of an ignore file.
+type ignoreRule struct {
+	glob    glob
+	negate  bool
+	dirOnly bool
+}
+
+// ignoreList is the rules of one ignore file.
+type ignoreList struct {
+	// base is the directory, relative to the root, that the patterns are
+	// relative to. For ignore files above the root it is "." and prefix is
+	// the path from their directory down to the root.
+	base, prefix string
+	rules        []ignoreRule
+}
+
+// parseIgnore reads the rules of an ignore file.
+func parseIgnore(data []byte, base, prefix string) *ignoreList {
+	list := &ignoreList{base: base, prefix: prefix}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSuffix(scanner.Text(), "\r")
+		// Trailing spaces are dropped unless escaped with a backslash.
+		for strings.HasSuffix(line, " ") && !strings.HasSuffix(line, "\\ ") {
+			line = line[:len(line)-1]
+		}
+		if line == "" || line[0] == '#' {
+			continue
+		}
+
+		negate := line[0] == '!'
+		if negate {
+			line = line[1:]
+		}
+		if rule, err := compileRule(line); err == nil {
+			rule.negate = negate
+			list.rules = append(list.rules, rule)
+		}
+	}
+	return list
+}
+
+// compileRule compiles a gitignore pattern, without its negation. A pattern
+// with a slash, other than a trailing one, is relative to the directory of
+// the ignore file. Otherwise it matches names at any depth. A trailing slash
+// matches only directories.
+func compileRule(pattern string) (ignoreRule, error) {
+	var rule ignoreRule
+	if strings.HasSuffix(pattern, "/") {
+		rule.dirOnly, pattern = true, strings.TrimSuffix(pattern, "/")
+	}
+	if !strings.Contains(pattern, "/") {
+		pattern = "**/" + pattern
+	}
+	pattern = strings.TrimPrefix(pattern, "/")
+	if pattern == "" {
+		return rule, path.ErrBadPattern
+	}
+	var err error
+	rule.glob, err = compileGlob(pattern)
+	return rule, err
+}
+
+// match reports whether the rule matches name, relative to the directory of
+// its pattern.
+func (r ignoreRule) match(name string, isDir bool) bool {
+	return (isDir || !r.dirOnly) && r.glob.match(name)
+}
+
+// match returns whether the rules ignore rel, relative to the root, given
+// whether an earlier list ignored it.
+func (l *ignoreList) match(rel string, isDir, ignored bool) bool {
+	if l.base != "." {
+		rel = rel[len(l.base)+1:]
+	}
+	rel = l.prefix + rel
+	for _, rule := range l.rules {
+		if rule.match(rel, isDir) {
+			ignored = !rule.negate
+		}
+	}
+	return ignored
+}
+
+// ignoreDir is the ignore lists that apply in a directory, from the lowest
+// precedence.
+type ignoreDir struct {
+	lists []*ignoreList
+	// repo is set in a git repository.
+	repo bool
+}
+
+// ignorer tracks the ignore files of the directories walked by SearchFS.
+type ignorer struct {
+	fsys    fs.FS
+	sources IgnoreSource
+	// above applies in the root, from the directories above it.
+	above *ignoreDir
+	// dirs maps every directory walked, by its path relative to the root,
+	// to the lists that apply in it.
+	dirs map[string]*ignoreDir
+
+	global       []byte
+	globalLoaded bool
+}
+
+// newIgnorer returns nil if nothing is ignored.
+func (f *finder) newIgnorer(fsys fs.FS, root string) *ignorer {
+	if f.ignore == 0 {
+		return nil
+	}
+	ig := &ignorer{fsys: fsys, sources: f.ignore, above: &ignoreDir{}, dirs: map[string]*ignoreDir{}}
+	if _, ok := fsys.(osFS); ok {
+		ig.loadAbove(root)
+	}
+	return ig
+}
+
+// loadAbove loads the ignore files of the directories above root, in the
+// operating system's file system.
+func (ig *ignorer) loadAbove(root string) {
+	abs, err := filepath.Abs(filepath.FromSlash(root))
+	if err != nil {
+		return
+	}
+	if abs == filepath.Dir(abs) {
+		return
+	}
+	var dirs []string
+	for dir := filepath.Dir(abs); ; dir = filepath.Dir(dir) {
+		dirs = append(dirs, dir)
+		if dir == filepath.Dir(dir) {
+			break
+		}
+	}
+
+	// Only the ignore files of the nearest repository apply.
+	top := len(dirs)
+	for i, dir := range dirs {
+		if _, err := os.Lstat(filepath.Join(dir, ".git")); err == nil {
+			top = i
+			break
+		}
+	}
+	for i := len(dirs) - 1; i >= 0; i-- {
+		rel, err := filepath.Rel(dirs[i], abs)
+		if err != nil {
+			return
+		}
+		prefix := filepath.ToSlash(rel) + "/"
+		name := filepath.ToSlash(dirs[i])
+		if i == top {
+			ig.above.repo = true
+			ig.above.lists = append(ig.above.lists, ig.repoLists(name, ".", prefix)...)
+		}
+		ig.above.lists = append(ig.above.lists, ig.dirLists(name, ".", prefix, ig.above.repo)...)
+	}
+}
+
+// enter loads the ignore files of the directory name, at rel from the root.
+func (ig *ignorer) enter(name, rel string) {
+	parent := ig.above
+	if rel != "." {
+		parent = ig.dirs[path.Dir(rel)]
+	}
+	dir := &ignoreDir{lists: parent.lists[:len(parent.lists):len(parent.lists)], repo: parent.repo}
+	if _, err := fs.Stat(ig.fsys, path.Join(name, ".git")); err == nil {
+		dir.repo = true
+		dir.lists = append(dir.lists, ig.repoLists(name, rel, "")...)
+	}
+	dir.lists = append(dir.lists, ig.dirLists(name, rel, "", dir.repo)...)
+	ig.dirs[rel] = dir
+}
+
+// ignored reports whether rel, a file or directory below the root, is
+// skipped.
+func (ig *ignorer) ignored(rel string, isDir bool) bool {
+	if isDir && path.Base(rel) == ".git" {
+		return true
+	}
+	ignored := false
+	if dir := ig.dirs[path.Dir(rel)]; dir != nil {
+		for _, list := range dir.lists {
+			ignored = list.match(rel, isDir, ignored)
+		}
+	}
+	return ignored
+}
+
+// repoLists loads the lists of the repository whose top directory is name.
+func (ig *ignorer) repoLists(name, base, prefix string) []*ignoreList {
+	var lists []*ignoreList
+	if ig.sources&IgnoreGlobal != 0 {
+		if !ig.globalLoaded {
+			ig.global, _ = os.ReadFile(globalExcludesFile())
+			ig.globalLoaded = true
+		}
+		lists = append(lists, parseIgnore(ig.global, base, prefix))
+	}
+	if ig.sources&IgnoreGitExclude != 0 {
+		if data, err := fs.ReadFile(ig.fsys, path.Join(name, ".git", "info", "exclude")); err == nil {
+			lists = append(lists, parseIgnore(data, base, prefix))
+		}
+	}
+	return lists
+}
+
+// dirLists loads the ignore files in the directory name.
+func (ig *ignorer) dirLists(name, base, prefix string, repo bool) []*ignoreList {
+	var lists []*ignoreList
+	for _, file := range []struct {
+		name   string
+		source IgnoreSource
+	}{{".gitignore", IgnoreGit}, {".ignore", IgnoreDot}} {
+		if ig.sources&file.source == 0 || (file.source == IgnoreGit && !repo) {
+			continue
+		}
+		if data, err := fs.ReadFile(ig.fsys, path.Join(name, file.name)); err == nil {
+			lists = append(lists, parseIgnore(data, base, prefix))
+		}
+	}
+	return lists
+}
+
+// globalExcludesFile returns the path of the global git excludes file.
+func globalExcludesFile() string {
+	home, _ := os.UserHomeDir()
+	config := os.Getenv("XDG_CONFIG_HOME")
+	if config == "" && home != "" {
+		config = filepath.Join(home, ".config")
+	}
+
+	file := ""
+	// ~/.gitconfig is read last, as its settings win.
+	for _, dir := range []string{config, home} {
+		if dir == "" {
+			continue
+		}
+		name := filepath.Join(dir, ".gitconfig")
+		if dir == config {
+			name = filepath.Join(dir, "git", "config")
+		}
+		if value, ok := gitConfigValue(name, "core", "excludesfile"); ok {
+			file = value
+		}
+	}
+	switch {
+	case file == "" && config != "":
+		return filepath.Join(config, "git", "ignore")
+	case strings.HasPrefix(file, "~/") && home != "":
+		return filepath.Join(home, file[2:])
+	}
+	return file
+}
+
+// gitConfigValue returns the last value of key in section of the git
+// configuration file name. Includes and subsections aren't supported.
+func gitConfigValue(name, section, key string) (value string, found bool) {
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return "", false
+	}
+	inSection := false
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if strings.HasPrefix(line, "[") {
+			header := strings.TrimSpace(strings.Trim(line, "[]"))
+			inSection = strings.EqualFold(header, section)
+			continue
+		}
+		k, v, ok := strings.Cut(line, "=")
+		if !inSection || !ok || !strings.EqualFold(strings.TrimSpace(k), key) {
+			continue
+		}
+		value, found = parseConfigValue(v), true
+	}
+	return value, found
+}
+
+// parseConfigValue drops the quotes and the comment of a git configuration
+// value.
+func parseConfigValue(v string) string {
+	var value strings.Builder
+	quoted := false
+	for i := 0; i < len(v); i++ {
+		switch c := v[i]; {
+		case c == '"':
+			quoted = !quoted
+		case c == '\\' && i+1 < len(v):
+			i++
+			value.WriteByte(v[i])
+		case (c == '#' || c == ';') && !quoted:
+			return strings.TrimSpace(value.String())
+		default:
+			value.WriteByte(c)
+		}
+	}
+	return strings.TrimSpace(value.String())
+}
+
+// relName returns the path of name, walked from root, relative to root.
+func relName(root, name string) string {
+	if name == root {
+		return "."
+	}
+	switch root = path.Clean(root); {
+	case root == ".":
+		return name
+	case root == "/":
+		return name[1:]
+	}
+	return name[len(root)+1:]
+}
@@ -0,0 +1,129 @@
+//go:build !time
+
+package grep_test
+
+import (
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+	"testing/fstest"
+
+	"github.com/alex123012/go-grep"
+)
+
+// foundFiles returns the names of the files in fileMap relative to dir, sorted.
+func foundFiles(t *testing.T, fileMap *grep.MapFiles, dir string) []string {
+	t.Helper()
+	var names []string
+	for _, file := range fileMap.GetStruct() {
+		name, err := filepath.Rel(dir, file.Name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		names = append(names, filepath.ToSlash(name))
+	}
+	sort.Strings(names)
+	return names
+}
+
+func TestIgnoreFiles(t *testing.T) {
+	config := t.TempDir()
+	t.Setenv("HOME", config)
+	t.Setenv("XDG_CONFIG_HOME", config)
+	writeFileInDir(t, config, "git/config", "[user]\n\tname = someone\n[core]\n\texcludesFile = \"~/global ignore\" ; comment\n")
+	writeFileInDir(t, config, "global ignore", "global.txt\n")
+
+	dir := t.TempDir()
+	for name, content := range map[string]string{
+		".git/HEAD":         "kill",
+		".git/info/exclude": "# comment\nexcluded.txt\n",
+		".gitignore":        "*.log\n!keep.log\nbuild/\n/top.txt\ndocs/*.md\n",
+		"a.txt":             "kill",
+		"x.log":             "kill",
+		"keep.log":          "kill",
+		"build/out.txt":     "kill",
+		"top.txt":           "kill",
+		"docs/a.md":         "kill",
+		"docs/sub/b.md":     "kill",
+		"excluded.txt":      "kill",
+		"global.txt":        "kill",
+		"src/.gitignore":    "!x2.log\nlocal.txt  \n",
+		"src/.ignore":       "dot.txt\n",
+		"src/build":         "kill",
+		"src/top.txt":       "kill",
+		"src/x2.log":        "kill",
+		"src/local.txt":     "kill",
+		"src/dot.txt":       "kill",
+	} {
+		writeFileInDir(t, dir, name, content)
+	}
+
+	all := []string{"a.txt", "docs/sub/b.md", "keep.log", "src/build", "src/top.txt", "src/x2.log"}
+	testCases := []struct {
+		sources  grep.IgnoreSource
+		expected []string
+	}{
+		{grep.IgnoreAll, nil},
+		{grep.IgnoreAll &^ grep.IgnoreGlobal, []string{"global.txt"}},
+		{grep.IgnoreAll &^ grep.IgnoreGitExclude, []string{"excluded.txt"}},
+		{grep.IgnoreAll &^ grep.IgnoreDot, []string{"src/dot.txt"}},
+		{grep.IgnoreAll &^ grep.IgnoreGit, []string{"build/out.txt", "docs/a.md", "src/local.txt", "top.txt", "x.log"}},
+		{0, []string{".git/HEAD", "build/out.txt", "docs/a.md", "excluded.txt", "global.txt", "src/dot.txt", "src/local.txt", "top.txt", "x.log"}},
+	}
+	for _, testCase := range testCases {
+		patternSearch := grep.MakeStringFinder("kill")
+		patternSearch.SetIgnore(testCase.sources)
+		patternSearch.SetHidden(true)
+		fileMap, err := patternSearch.Search(dir, true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected := append(append([]string{}, all...), testCase.expected...)
+		sort.Strings(expected)
+		if result := foundFiles(t, fileMap, dir); !reflect.DeepEqual(result, expected) {
+			t.Errorf("Expected %v with sources %d, but got %v", expected, testCase.sources, result)
+		}
+	}
+
+	// The ignore files above the root apply too, but not to the root itself.
+	patternSearch := grep.MakeStringFinder("kill")
+	fileMap, err := patternSearch.Search(filepath.Join(dir, "src"), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"src/build", "src/top.txt", "src/x2.log"}
+	if result := foundFiles(t, fileMap, dir); !reflect.DeepEqual(result, expected) {
+		t.Errorf("Expected %v, but got %v", expected, result)
+	}
+	fileMap, err = patternSearch.Search(filepath.Join(dir, "x.log"), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fileMap.Len() != 1 {
+		t.Errorf("Expected x.log given as the root to be searched, but got %+v", fileMap.GetStruct())
+	}
+}
+
+func TestIgnoreOutsideRepository(t *testing.T) {
+	fsys := fstest.MapFS{
+		".gitignore":        {Data: []byte("*.txt\n")},
+		".ignore":           {Data: []byte("vendor/\n**/gen/**\n[!a]*.md\n")},
+		"a.txt":             {Data: []byte("kill")},
+		"a.md":              {Data: []byte("kill")},
+		"b.md":              {Data: []byte("kill")},
+		"vendor/lib.go":     {Data: []byte("kill")},
+		"pkg/gen/out.go":    {Data: []byte("kill")},
+		"pkg/gen/deep/x.go": {Data: []byte("kill")},
+		"pkg/gen.go":        {Data: []byte("kill")},
+	}
+	fileMap, err := grep.MakeStringFinder("kill").SearchFS(fsys, ".", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// .gitignore applies only in git repositories.
+	expected := []string{"a.md", "a.txt", "pkg/gen.go"}
+	if result := foundFiles(t, fileMap, "."); !reflect.DeepEqual(result, expected) {
+		t.Errorf("Expected %v, but got %v", expected, result)
+	}
+}
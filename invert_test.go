@@ -0,0 +1,61 @@
+//go:build !time
+
+package grep_test
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/alex123012/go-grep"
+)
+
+func TestInvertLines(t *testing.T) {
+	fileName := "./test_cases/test_dir/test_short_text_file.txt"
+	patternSearch := grep.MakeStringFinder("A0A")
+	patternSearch.SetInvert(true)
+	fileMap, err := patternSearch.Search(fileName, false)
+	if err != nil {
+		t.Fatalf("Error in executing test on %s: %v", fileName, err)
+	}
+
+	v, f := fileMap.Get(fileName)
+	if len := v.(grep.SyncMap).Len(); len != 103 || !f {
+		t.Fatalf("Expected %d inverted lines, but got %d", 103, len)
+	}
+	if _, f := v.(grep.SyncMap).Get(2); f {
+		t.Fatal("Line 2 contains the pattern and must not be selected")
+	}
+	if line, f := v.(grep.SyncMap).Get(1); !f || line.(*grep.Line).Text != "access" {
+		t.Fatalf("Expected line 1 to be selected, but got %+v", line)
+	}
+}
+
+func TestInvertFiles(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"licensed.go":   "// Copyright 2022\npackage grep\n",
+		"unlicensed.go": "package grep\n",
+		"empty.go":      "",
+	}
+	for name, content := range files {
+		writeFileInDir(t, dir, name, content)
+	}
+
+	patternSearch := grep.MakeStringFinder("Copyright")
+	patternSearch.SetInvert(true)
+	fileMap, err := patternSearch.Search(dir, true)
+	if err != nil {
+		t.Fatalf("Error in executing test on %s: %v", dir, err)
+	}
+	if v := fileMap.Len(); v != 2 {
+		t.Fatalf("Expected 2 files without a match, but got %d", v)
+	}
+	if _, f := fileMap.Get(filepath.Join(dir, "licensed.go")); f {
+		t.Fatal("File with a match must not be selected")
+	}
+	for _, file := range fileMap.GetStruct() {
+		if len(file.Lines) != 0 {
+			t.Fatalf("Expected no lines for %s, but got %d", file.Name, len(file.Lines))
+		}
+	}
+}
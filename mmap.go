@@ -0,0 +1,148 @@
+package grep
+
+import (
+	"bytes"
+	"errors"
+	"io/fs"
+	"os"
+	"runtime/debug"
+)
+
+// DefaultMmapThreshold is the size from which Search maps files into memory
+// unless SetMmapThreshold is called.
+const DefaultMmapThreshold = 64 << 20
+
+// errMappingFault is returned by guardFault when the mapped file can't be
+// read any more, usually because it was truncated.
+var errMappingFault = errors.New("mapped file changed while searching")
+
+// errMmapUnsupported is returned by mmap on systems without a mapped read
+// path.
+var errMmapUnsupported = errors.New("mmap is not supported on this system")
+
+// SetMmapThreshold makes Search map regular files of at least size bytes into
+// memory instead of reading them in chunks, which saves copying the content of
+// large files. StringFinder, MultiFinder and BytesFinder then search the whole
+// mapping at once and recover the text only of the lines that match. Zero or
+// a negative size disables mapping. Mapping is only supported on Linux; other
+// systems, and files that can't be mapped or shrink while mapped, are read as
+// usual.
+func (f *finder) SetMmapThreshold(size int64) {
+	f.mmapThreshold = size
+}
+
+// withMapping maps file into memory and calls fn with its content, if file is
+// a regular file of at least mmapThreshold bytes. If mapped is false, the file
+// wasn't mapped or changed under the mapping, and must be read from the start
+// instead.
+func (f *finder) withMapping(file fs.File, fn func(buf []byte) error) (mapped bool, err error) {
+	osFile, ok := file.(*os.File)
+	if !ok || f.mmapThreshold <= 0 {
+		return false, nil
+	}
+	info, err := file.Stat()
+	if err != nil || !info.Mode().IsRegular() || info.Size() < f.mmapThreshold {
+		return false, nil
+	}
+	size := int(info.Size())
+	if int64(size) != info.Size() {
+		// The file doesn't fit into the address space.
+		return false, nil
+	}
+	buf, err := mmap(osFile, size)
+	if err != nil {
+		return false, nil
+	}
+	defer munmap(buf)
+
+	err = guardFault(func() error {
+		return fn(buf)
+	})
+	if errors.Is(err, errMappingFault) {
+		return false, nil
+	}
+	return true, err
+}
+
+// guardFault calls fn, turning a fault on reading a mapping into
+// errMappingFault instead of crashing the program.
+func guardFault(fn func() error) (err error) {
+	defer debug.SetPanicOnFault(debug.SetPanicOnFault(true))
+	defer func() {
+		if r := recover(); r != nil {
+			if _, ok := r.(interface{ Addr() uintptr }); !ok {
+				panic(r)
+			}
+			err = errMappingFault
+		}
+	}()
+	return fn()
+}
+
+// candidateFinder is implemented by finders that can skip over a whole file
+// to the lines that may match.
+type candidateFinder interface {
+	// candidate returns the index of a byte in text such that no line before
+	// the one holding it matches, or -1 if no line of text matches.
+	candidate(text []byte) int
+}
+
+// candidate ignores the boundary, which matchLine checks on the line itself
+// once a carriage return before the newline is dropped.
+func (f *StringFinder) candidate(text []byte) int {
+	return f.find(text, 0, false)
+}
+
+func (f *MultiFinder) candidate(text []byte) int {
+	if f.empty != -1 {
+		// Every line matches.
+		return 0
+	}
+	state := 0
+	for i, b := range text {
+		state = f.step(state, b)
+		if len(f.states[state].out) > 0 {
+			// The last byte of the first occurrence to end.
+			return i
+		}
+	}
+	return -1
+}
+
+func (f *BytesFinder) candidate(text []byte) int {
+	return f.index(text, 0)
+}
+
+// candidateMatch searches the whole of buf with finder and matches only the
+// lines holding candidates.
+func (f *finder) candidateMatch(buf []byte, finder candidateFinder, res *fileResult) {
+	// pos is the start of line number.
+	number, pos := 1, 0
+	for pos < len(buf) {
+		i := finder.candidate(buf[pos:])
+		if i == -1 {
+			break
+		}
+		lineStart := pos
+		if j := bytes.LastIndexByte(buf[pos:pos+i], '\n'); j != -1 {
+			number += bytes.Count(buf[pos:pos+j], []byte{'\n'}) + 1
+			lineStart = pos + j + 1
+		}
+		lineEnd := len(buf)
+		if j := bytes.IndexByte(buf[pos+i:], '\n'); j != -1 {
+			lineEnd = pos + i + j
+		}
+
+		text := dropCR(buf[lineStart:lineEnd])
+		line := &Line{Number: number}
+		if f.matcher.matchLine(text, line) {
+			line.Text = string(f.capText(text, 0))
+			if len(line.Spans) > 0 {
+				line.Column = line.Spans[0].Start + 1
+			}
+			res.put(number, line)
+		}
+		number++
+		pos = lineEnd + 1
+	}
+}
@@ -0,0 +1,16 @@
+//go:build linux
+
+package grep
+
+import (
+	"os"
+	"syscall"
+)
+
+func mmap(file *os.File, size int) ([]byte, error) {
+	return syscall.Mmap(int(file.Fd()), 0, size, syscall.PROT_READ, syscall.MAP_SHARED)
+}
+
+func munmap(buf []byte) error {
+	return syscall.Munmap(buf)
+}
@@ -0,0 +1,13 @@
+//go:build !linux
+
+package grep
+
+import "os"
+
+func mmap(file *os.File, size int) ([]byte, error) {
+	return nil, errMmapUnsupported
+}
+
+func munmap(buf []byte) error {
+	return errMmapUnsupported
+}
@@ -0,0 +1,106 @@
+//go:build !time
+
+package grep_test
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/alex123012/go-grep"
+)
+
+type mmapSearcher interface {
+	Search(path string, onlyFiles bool) (*grep.MapFiles, error)
+	SetMmapThreshold(size int64)
+	SetInvert(invert bool)
+}
+
+func TestMmap(t *testing.T) {
+	content := "first needle line\r\n" +
+		"no match\n" +
+		"needle and needle\n" +
+		strings.Repeat("x", 200000) + "needle" + strings.Repeat("y", 1000) + "\n" +
+		"needle\nsplit\n" +
+		"\n" +
+		"last needle"
+	fileName := writeTestFile(t, "mmap.txt", content)
+
+	regexpFinder, err := grep.MakeRegexpFinder(`need(le)?`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	finders := map[string]mmapSearcher{
+		"StringFinder":     grep.MakeStringFinder("needle"),
+		"MultiFinder":      grep.MakeMultiFinder([]string{"needle\nsplit", "and", "match"}),
+		"MultiFinderEmpty": grep.MakeMultiFinder([]string{"", "needle"}),
+		"BytesFinder":      grep.MakeBytesFinder([]byte("le\r")),
+		"RegexpFinder":     regexpFinder,
+	}
+	for name, finder := range finders {
+		for _, invert := range []bool{false, true} {
+			for _, onlyFiles := range []bool{false, true} {
+				finder.SetInvert(invert)
+				finder.SetMmapThreshold(0)
+				expected, err := finder.Search(fileName, onlyFiles)
+				if err != nil {
+					t.Fatal(err)
+				}
+				finder.SetMmapThreshold(1)
+				fileMap, err := finder.Search(fileName, onlyFiles)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !reflect.DeepEqual(searchResults(fileMap), searchResults(expected)) {
+					t.Errorf("%s with invert %v and onlyFiles %v: expected %+v, but got %+v",
+						name, invert, onlyFiles, searchResults(expected), searchResults(fileMap))
+				}
+			}
+		}
+	}
+
+	// The carriage return before the newline is dropped before the boundary
+	// is checked.
+	crlfName := writeTestFile(t, "crlf.txt", "id\r\nx\r\n")
+	wholeLine := grep.MakeStringFinder("id")
+	wholeLine.SetBoundary(grep.WholeLine)
+	for _, threshold := range []int64{0, 1} {
+		wholeLine.SetMmapThreshold(threshold)
+		fileMap, err := wholeLine.Search(crlfName, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result := searchResults(fileMap)[crlfName]; len(result) != 1 || result[1].Text != "id" {
+			t.Errorf("Expected line 1 of the CRLF file with mmap threshold %d, but got %+v", threshold, result)
+		}
+	}
+}
+
+func TestMmapFileQuery(t *testing.T) {
+	fileName := writeTestFile(t, "mmap.txt", "TODO\nDeprecated\n")
+	query, err := grep.MakeFileQuery("Deprecated AND TODO")
+	if err != nil {
+		t.Fatal(err)
+	}
+	query.SetMmapThreshold(1)
+	fileMap, err := query.Search(fileName, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, f := fileMap.Get(fileName); !f {
+		t.Fatalf("Expected %s to be selected", fileName)
+	}
+}
+
+// searchResults returns the lines found in every file by their number.
+func searchResults(fileMap *grep.MapFiles) map[string]map[int]grep.Line {
+	result := map[string]map[int]grep.Line{}
+	for _, file := range fileMap.GetStruct() {
+		lines := map[int]grep.Line{}
+		for _, line := range file.Lines {
+			lines[line.Number] = *line
+		}
+		result[file.Name] = lines
+	}
+	return result
+}
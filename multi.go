@@ -0,0 +1,236 @@
+package grep
+
+import (
+	"bufio"
+	"bytes"
+	"os"
+	"sort"
+	"unicode/utf8"
+)
+
+// MultiFinder finds lines containing any of several literal patterns in a
+// single pass over each line. It is implemented with an Aho-Corasick
+// automaton:
+// https://en.wikipedia.org/wiki/Aho-Corasick_algorithm
+type MultiFinder struct {
+	patterns []string
+
+	// states of the automaton, states[0] is the root. A state is the prefix
+	// of one or more patterns.
+	states []acState
+	// empty is the index of the empty pattern, which matches everywhere and
+	// isn't in the automaton, or -1.
+	empty int
+
+	*finder
+}
+
+type acState struct {
+	next map[byte]int
+
+	// fail is the state for the longest proper suffix of this state's prefix
+	// that is also a prefix of some pattern.
+	fail int
+
+	// out holds indexes of the patterns ending in this state, including the
+	// ones reachable through fail links.
+	out []int
+}
+
+// MakeMultiFinder builds a finder for patterns. Duplicate patterns are
+// ignored. An empty pattern matches every line, as an empty line of a grep -f
+// pattern file does.
+func MakeMultiFinder(patterns []string) *MultiFinder {
+	f := &MultiFinder{
+		states: []acState{{next: map[byte]int{}}},
+		empty:  -1,
+	}
+	seen := make(map[string]bool, len(patterns))
+	for _, pattern := range patterns {
+		if seen[pattern] {
+			continue
+		}
+		seen[pattern] = true
+		f.addPattern(pattern)
+	}
+	f.buildFailLinks()
+	f.finder = makeFinder(f)
+	return f
+}
+
+// MakeMultiFinderFromFile reads patterns from fileName, one per line, the way
+// grep -f does.
+func MakeMultiFinderFromFile(fileName string) (*MultiFinder, error) {
+	file, err := os.Open(fileName)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var patterns []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		patterns = append(patterns, string(bytes.TrimSuffix(scanner.Bytes(), []byte{'\r'})))
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return MakeMultiFinder(patterns), nil
+}
+
+// Patterns returns the patterns f searches for.
+func (f *MultiFinder) Patterns() []string {
+	return f.patterns
+}
+
+func (f *MultiFinder) addPattern(pattern string) {
+	if pattern == "" {
+		f.empty = len(f.patterns)
+		f.patterns = append(f.patterns, pattern)
+		return
+	}
+	state := 0
+	for i := 0; i < len(pattern); i++ {
+		next, ok := f.states[state].next[pattern[i]]
+		if !ok {
+			next = len(f.states)
+			f.states = append(f.states, acState{next: map[byte]int{}})
+			f.states[state].next[pattern[i]] = next
+		}
+		state = next
+	}
+	f.states[state].out = append(f.states[state].out, len(f.patterns))
+	f.patterns = append(f.patterns, pattern)
+}
+
+// buildFailLinks visits states in breadth-first order, so the fail state of
+// a parent is always complete before its children are processed.
+func (f *MultiFinder) buildFailLinks() {
+	queue := []int{}
+	for _, child := range f.states[0].next {
+		queue = append(queue, child)
+	}
+	for len(queue) > 0 {
+		state := queue[0]
+		queue = queue[1:]
+		for b, child := range f.states[state].next {
+			queue = append(queue, child)
+
+			fail := f.states[state].fail
+			next, ok := f.states[fail].next[b]
+			for !ok && fail != 0 {
+				fail = f.states[fail].fail
+				next, ok = f.states[fail].next[b]
+			}
+			if ok && next != child {
+				f.states[child].fail = next
+				f.states[child].out = append(f.states[child].out, f.states[next].out...)
+			}
+		}
+	}
+}
+
+func (f *MultiFinder) maxMatchLen() int {
+	result := 0
+	for _, pattern := range f.patterns {
+		if len(pattern) > result {
+			result = len(pattern)
+		}
+	}
+	return result
+}
+
+// step returns the state after reading b in state.
+func (f *MultiFinder) step(state int, b byte) int {
+	for {
+		if next, ok := f.states[state].next[b]; ok {
+			return next
+		}
+		if state == 0 {
+			return 0
+		}
+		state = f.states[state].fail
+	}
+}
+
+// acMatch is an occurrence of patterns[pattern] ending before text[end].
+type acMatch struct {
+	pattern int
+	end     int
+}
+
+func (f *MultiFinder) scan(text []byte) []acMatch {
+	var found []acMatch
+	state := 0
+	for i, b := range text {
+		if f.empty != -1 && utf8.RuneStart(b) {
+			found = append(found, acMatch{pattern: f.empty, end: i})
+		}
+		state = f.step(state, b)
+		for _, pattern := range f.states[state].out {
+			found = append(found, acMatch{pattern: pattern, end: i + 1})
+		}
+	}
+	if f.empty != -1 {
+		found = append(found, acMatch{pattern: f.empty, end: len(text)})
+	}
+	return found
+}
+
+// matchLine records every pattern found in line in res.Patterns, in the
+// order the patterns were given.
+func (f *MultiFinder) matchLine(line []byte, res *Line) bool {
+	found := f.scan(line)
+	if len(found) == 0 {
+		return false
+	}
+
+	patterns := make([]int, len(found))
+	for i, match := range found {
+		patterns[i] = match.pattern
+	}
+	sort.Ints(patterns)
+	res.Patterns = res.Patterns[:0]
+	for i, pattern := range patterns {
+		if i == 0 || pattern != patterns[i-1] {
+			res.Patterns = append(res.Patterns, f.patterns[pattern])
+		}
+	}
+	res.Spans = f.spans(found, f.overlapping)
+	return true
+}
+
+// FindAll returns the spans of all pattern occurrences in text. Without
+// overlapping the leftmost occurrence wins, and the longest one among those
+// starting at the same byte.
+func (f *MultiFinder) FindAll(text []byte, overlapping bool) []Span {
+	return f.spans(f.scan(text), overlapping)
+}
+
+func (f *MultiFinder) spans(found []acMatch, overlapping bool) []Span {
+	spans := make([]Span, 0, len(found))
+	for _, match := range found {
+		spans = append(spans, Span{
+			Start: match.end - len(f.patterns[match.pattern]),
+			End:   match.end,
+		})
+	}
+	sort.Slice(spans, func(i, j int) bool {
+		if spans[i].Start != spans[j].Start {
+			return spans[i].Start < spans[j].Start
+		}
+		return spans[i].End > spans[j].End
+	})
+
+	result := spans[:0]
+	for _, span := range spans {
+		if len(result) > 0 {
+			last := result[len(result)-1]
+			if span == last || (!overlapping && span.Start < last.End) {
+				continue
+			}
+		}
+		result = append(result, span)
+	}
+	return result
+}
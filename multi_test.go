@@ -0,0 +1,92 @@
+//go:build !time
+
+package grep_test
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/alex123012/go-grep"
+)
+
+func TestMultiFinder(t *testing.T) {
+	fileName := "./test_cases/test_dir/test_short_text_file.txt"
+	patternSearch := grep.MakeMultiFinder([]string{"Q9", "P0", "A0A", "Q9"})
+	if v := patternSearch.Patterns(); len(v) != 3 {
+		t.Fatalf("Expected duplicate patterns to be dropped, but got %q", v)
+	}
+
+	fileMap, err := patternSearch.Search(fileName, false)
+	if err != nil {
+		t.Fatalf("Error in executing test on %s: %v", fileName, err)
+	}
+	v, f := fileMap.Get(fileName)
+	if len := v.(grep.SyncMap).Len(); len != 39 || !f {
+		t.Fatalf("Expected %d in MultiFinder.Search, but got %d", 39, len)
+	}
+
+	line, _ := v.(grep.SyncMap).Get(11)
+	if patterns := line.(*grep.Line).Patterns; !reflect.DeepEqual(patterns, []string{"Q9", "P0"}) {
+		t.Fatalf("Expected line 11 to match [Q9 P0], but got %q", patterns)
+	}
+}
+
+func TestMultiFinderOverlapping(t *testing.T) {
+	fileName := writeTestFile(t, "overlap.txt", "ushers\nhis\nnothing\n")
+	patternSearch := grep.MakeMultiFinder([]string{"he", "she", "his", "hers"})
+	fileMap, err := patternSearch.Search(fileName, false)
+	if err != nil {
+		t.Fatalf("Error in executing test on %s: %v", fileName, err)
+	}
+
+	result := fileMap.GetStruct()
+	if len(result) != 1 || len(result[0].Lines) != 2 {
+		t.Fatalf("Expected 2 matched lines, but got %+v", result)
+	}
+	v, _ := fileMap.Get(fileName)
+	line, _ := v.(grep.SyncMap).Get(1)
+	if patterns := line.(*grep.Line).Patterns; !reflect.DeepEqual(patterns, []string{"he", "she", "hers"}) {
+		t.Fatalf("Expected line 1 to match [he she hers], but got %q", patterns)
+	}
+}
+
+func TestMultiFinderFromFile(t *testing.T) {
+	patternsFile := writeTestFile(t, "patterns.txt", strings.Join([]string{"Q9DG71", "P13987\r", "NOTFOUND"}, "\n"))
+	patternSearch, err := grep.MakeMultiFinderFromFile(patternsFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fileName := "./test_cases/test_dir/test_short_text_file.txt"
+	fileMap, err := patternSearch.Search(fileName, false)
+	if err != nil {
+		t.Fatalf("Error in executing test on %s: %v", fileName, err)
+	}
+	v, f := fileMap.Get(fileName)
+	if len := v.(grep.SyncMap).Len(); len != 2 || !f {
+		t.Fatalf("Expected %d in MultiFinder.Search, but got %d", 2, len)
+	}
+
+	// An empty line is a pattern that matches every line.
+	patternsFile = writeTestFile(t, "empty.txt", "NOTFOUND\n\n")
+	patternSearch, err = grep.MakeMultiFinderFromFile(patternsFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fileMap, err = patternSearch.Search(fileName, false)
+	if err != nil {
+		t.Fatalf("Error in executing test on %s: %v", fileName, err)
+	}
+	v, _ = fileMap.Get(fileName)
+	if len := v.(grep.SyncMap).Len(); len != 116 {
+		t.Fatalf("Expected all 116 lines to match the empty pattern, but got %d", len)
+	}
+	line, _ := v.(grep.SyncMap).Get(1)
+	if line := line.(*grep.Line); line.Column != 1 || !reflect.DeepEqual(line.Patterns, []string{""}) {
+		t.Fatalf("Expected line 1 to match the empty pattern at column 1, but got %+v", line)
+	}
+
+	if _, err := grep.MakeMultiFinderFromFile("./test_cases/no_such_file"); err == nil {
+		t.Fatal("Expected error for missing patterns file")
+	}
+}
@@ -0,0 +1,117 @@
+package grep
+
+import "bytes"
+
+// spanFinder is implemented by finders that can report where their matches
+// are in an arbitrary text.
+type spanFinder interface {
+	FindAll(text []byte, overlapping bool) []Span
+}
+
+// SetMultiline makes Search match the pattern against the whole file instead
+// of line by line, so a match may span several lines. A stored Line then
+// holds every line of the match, from Number to EndNumber, and its Spans are
+// relative to the start of Text. Matches starting on the same line share one
+// Line. A RegexpFinder then matches ^ and $ at the start and end of every
+// line, as with the m flag, while \A and \z match at the start and end of the
+// file. Finders without FindAll, like Query, always search line by line.
+func (f *finder) SetMultiline(multiline bool) {
+	f.multiline = multiline
+}
+
+func (f *finder) multilineMatch(buf []byte, finder spanFinder, res *fileResult) {
+	lines := multilineResults(buf, finder.FindAll(buf, f.overlapping))
+	switch {
+	case f.invert && f.onlyFiles:
+		res.selected = len(lines) == 0
+	case f.invert:
+		for _, line := range uncoveredLines(buf, lines) {
+			res.put(line.Number, line)
+		}
+	default:
+		for _, line := range lines {
+			line.Column = line.Spans[0].Start + 1
+			res.put(line.Number, line)
+		}
+	}
+}
+
+// multilineResults groups spans found in buf by the line they start on. The
+// spans must be sorted by their start.
+func multilineResults(buf []byte, spans []Span) []*Line {
+	var lines []*Line
+	var current *Line
+	currentEnd := 0
+
+	number, lineStart, pos := 1, 0, 0
+	for _, span := range spans {
+		if span.Start == len(buf) && len(buf) > 0 {
+			// An empty match after the last newline is not on any line.
+			break
+		}
+		number += bytes.Count(buf[pos:span.Start], []byte{'\n'})
+		if i := bytes.LastIndexByte(buf[pos:span.Start], '\n'); i != -1 {
+			lineStart = pos + i + 1
+		}
+		pos = span.Start
+
+		// last is the final byte of the match, or its start if it is empty.
+		last := span.Start
+		if span.End > span.Start {
+			last = span.End - 1
+		}
+		endNumber := number + bytes.Count(buf[span.Start:last], []byte{'\n'})
+		end := len(buf)
+		if i := bytes.IndexByte(buf[last:], '\n'); i != -1 {
+			end = last + i
+		}
+
+		if current == nil || current.Number != number {
+			current = &Line{Number: number, EndNumber: endNumber}
+			lines = append(lines, current)
+			currentEnd = end
+		}
+		if endNumber > current.EndNumber {
+			current.EndNumber = endNumber
+		}
+		if end > currentEnd {
+			currentEnd = end
+		}
+		current.Text = string(buf[lineStart:currentEnd])
+		current.Spans = append(current.Spans, Span{
+			Start:    span.Start - lineStart,
+			End:      span.End - lineStart,
+			Distance: span.Distance,
+		})
+	}
+	return lines
+}
+
+// uncoveredLines returns the lines of buf that are not part of any match in
+// lines.
+func uncoveredLines(buf []byte, lines []*Line) []*Line {
+	covered := map[int]bool{}
+	for _, line := range lines {
+		for number := line.Number; number <= line.EndNumber; number++ {
+			covered[number] = true
+		}
+	}
+
+	if len(buf) == 0 {
+		return nil
+	}
+	var result []*Line
+	buf = bytes.TrimSuffix(buf, []byte{'\n'})
+	for number := 1; buf != nil; number++ {
+		text := buf
+		if i := bytes.IndexByte(buf, '\n'); i != -1 {
+			text, buf = buf[:i], buf[i+1:]
+		} else {
+			buf = nil
+		}
+		if !covered[number] {
+			result = append(result, &Line{Number: number, Text: string(text)})
+		}
+	}
+	return result
+}
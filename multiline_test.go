@@ -0,0 +1,116 @@
+//go:build !time
+
+package grep_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/alex123012/go-grep"
+)
+
+const multilineText = `// Copyright 2022 The Authors.
+// All rights reserved.
+
+func Search(path string,
+	onlyFiles bool) error {
+	return nil
+}
+`
+
+func TestMultiline(t *testing.T) {
+	fileName := writeTestFile(t, "multiline.go", multilineText)
+	patternSearch := grep.MakeStringFinder("path string,\n\tonlyFiles")
+	patternSearch.SetMultiline(true)
+	fileMap, err := patternSearch.Search(fileName, false)
+	if err != nil {
+		t.Fatalf("Error in executing test on %s: %v", fileName, err)
+	}
+
+	v, f := fileMap.Get(fileName)
+	if !f || v.(grep.SyncMap).Len() != 1 {
+		t.Fatalf("Expected 1 multi-line match, but got %+v", fileMap.GetStruct())
+	}
+	line, _ := v.(grep.SyncMap).Get(4)
+	expected := &grep.Line{
+		Number:    4,
+		EndNumber: 5,
+		Text:      "func Search(path string,\n\tonlyFiles bool) error {",
+		Column:    13,
+		Spans:     []grep.Span{{Start: 12, End: 35}},
+	}
+	if !reflect.DeepEqual(line, expected) {
+		t.Fatalf("Expected %+v, but got %+v", expected, line)
+	}
+
+	patternSearch.SetMultiline(false)
+	fileMap, err = patternSearch.Search(fileName, false)
+	if err != nil {
+		t.Fatalf("Error in executing test on %s: %v", fileName, err)
+	}
+	if v := fileMap.Len(); v != 0 {
+		t.Fatalf("Expected no matches line by line, but got %d files", v)
+	}
+}
+
+func TestMultilineRegexp(t *testing.T) {
+	fileName := writeTestFile(t, "multiline.go", multilineText)
+	patternSearch, err := grep.MakeRegexpFinder(`(?m)^// Copyright.*\n(^//.*\n)*`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	patternSearch.SetMultiline(true)
+	fileMap, err := patternSearch.Search(fileName, false)
+	if err != nil {
+		t.Fatalf("Error in executing test on %s: %v", fileName, err)
+	}
+	v, _ := fileMap.Get(fileName)
+	line, f := v.(grep.SyncMap).Get(1)
+	if !f || line.(*grep.Line).EndNumber != 2 {
+		t.Fatalf("Expected the copyright block on lines 1-2, but got %+v", line)
+	}
+
+	patternSearch.SetInvert(true)
+	fileMap, err = patternSearch.Search(fileName, false)
+	if err != nil {
+		t.Fatalf("Error in executing test on %s: %v", fileName, err)
+	}
+	v, _ = fileMap.Get(fileName)
+	if len := v.(grep.SyncMap).Len(); len != 5 {
+		t.Fatalf("Expected 5 lines outside of the block, but got %d", len)
+	}
+}
+
+func TestMultilineRegexpAnchors(t *testing.T) {
+	fileName := writeTestFile(t, "multiline.go", multilineText)
+	testCases := []struct {
+		expr    string
+		numbers []int
+	}{
+		// ^ and $ match at every line without the m flag.
+		{expr: `^func .*\n.*\{$`, numbers: []int{4}},
+		{expr: `^}$`, numbers: []int{7}},
+		{expr: `\A//.*$`, numbers: []int{1}},
+		{expr: `(?-m)^}$`, numbers: nil},
+	}
+	for _, testCase := range testCases {
+		patternSearch, err := grep.MakeRegexpFinder(testCase.expr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		patternSearch.SetMultiline(true)
+		fileMap, err := patternSearch.Search(fileName, false)
+		if err != nil {
+			t.Fatalf("Error in executing test on %s: %v", fileName, err)
+		}
+		var numbers []int
+		for _, file := range fileMap.GetStruct() {
+			for _, line := range file.Lines {
+				numbers = append(numbers, line.Number)
+			}
+		}
+		if !reflect.DeepEqual(numbers, testCase.numbers) {
+			t.Errorf("Expected matches on lines %v for %q, but got %v", testCase.numbers, testCase.expr, numbers)
+		}
+	}
+}
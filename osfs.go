@@ -0,0 +1,34 @@
+package grep
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// readLinkFS is implemented by file systems that can read symbolic links.
+type readLinkFS interface {
+	fs.FS
+	ReadLink(name string) (string, error)
+}
+
+// osFS is the operating system's file system, which Search uses. Unlike
+// os.DirFS, it takes any path the os package does, including absolute ones,
+// and doesn't follow a symbolic link given as the root.
+type osFS struct{}
+
+func (osFS) Open(name string) (fs.File, error) {
+	return os.Open(filepath.FromSlash(name))
+}
+
+func (osFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	return os.ReadDir(filepath.FromSlash(name))
+}
+
+func (osFS) Stat(name string) (fs.FileInfo, error) {
+	return os.Lstat(filepath.FromSlash(name))
+}
+
+func (osFS) ReadLink(name string) (string, error) {
+	return os.Readlink(filepath.FromSlash(name))
+}
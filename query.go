@@ -0,0 +1,398 @@
+package grep
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// ErrQuerySyntax is returned by MakeQuery for malformed expressions.
+var ErrQuerySyntax = errors.New("query syntax error")
+
+// Query finds lines matching a boolean expression of literal terms, for
+// example:
+//
+//	kill AND (sword OR dagger) AND NOT "stage direction"
+//
+// The operators are AND, OR and NOT, with NOT binding tightest and OR
+// loosest. Adjacent terms without an operator are joined with AND. Terms
+// containing spaces, parentheses or operator names must be double-quoted.
+type Query struct {
+	*queryExpr
+	*finder
+}
+
+// FileQuery selects files by a boolean expression of literal terms, with the
+// syntax of Query. A term is true if it appears anywhere in the file, so
+//
+//	Deprecated AND TODO AND NOT nolint
+//
+// selects the files mentioning both Deprecated and TODO, but never nolint.
+// Reading a file stops as soon as the outcome is decided. Search returns only
+// the file names, regardless of its onlyFiles argument.
+type FileQuery struct {
+	*queryExpr
+	*finder
+}
+
+// queryExpr is a compiled boolean expression shared by Query and FileQuery.
+type queryExpr struct {
+	root *queryNode
+
+	// terms holds a searcher for every distinct term of the expression.
+	terms []*StringFinder
+	// positive marks the terms that appear outside of NOT, whose occurrences
+	// are reported in Line.Spans.
+	positive []bool
+}
+
+type queryOp int
+
+const (
+	termOp queryOp = iota
+	andOp
+	orOp
+	notOp
+)
+
+type queryNode struct {
+	op queryOp
+	// term indexes Query.terms for termOp nodes.
+	term int
+	subs []*queryNode
+}
+
+// MakeQuery compiles expr into a line query.
+func MakeQuery(expr string) (*Query, error) {
+	e, err := compileQuery(expr)
+	if err != nil {
+		return nil, err
+	}
+	q := &Query{queryExpr: e}
+	q.finder = makeFinder(q)
+	return q, nil
+}
+
+// MakeFileQuery compiles expr into a file query.
+func MakeFileQuery(expr string) (*FileQuery, error) {
+	e, err := compileQuery(expr)
+	if err != nil {
+		return nil, err
+	}
+	q := &FileQuery{queryExpr: e}
+	q.finder = makeFileFinder(q)
+	return q, nil
+}
+
+func compileQuery(expr string) (*queryExpr, error) {
+	tokens, err := tokenizeQuery(expr)
+	if err != nil {
+		return nil, err
+	}
+	p := &queryParser{tokens: tokens, terms: map[string]int{}}
+	root, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.tokens) {
+		return nil, fmt.Errorf("%w: unexpected %q", ErrQuerySyntax, p.tokens[p.pos].text)
+	}
+
+	e := &queryExpr{root: root}
+	e.terms = make([]*StringFinder, len(p.terms))
+	for term, i := range p.terms {
+		e.terms[i] = makeStringSearcher(term)
+	}
+	e.positive = make([]bool, len(p.terms))
+	e.markPositive(root, false)
+	return e, nil
+}
+
+func (e *queryExpr) markPositive(node *queryNode, negated bool) {
+	if node.op == termOp {
+		e.positive[node.term] = e.positive[node.term] || !negated
+		return
+	}
+	for _, sub := range node.subs {
+		e.markPositive(sub, negated != (node.op == notOp))
+	}
+}
+
+// matchLine evaluates the query for line and records the occurrences of all
+// the terms found in it.
+func (q *Query) matchLine(line []byte, res *Line) bool {
+	found := make([]termResult, len(q.terms))
+	if !q.eval(q.root, line, found) {
+		return false
+	}
+
+	res.Spans = res.Spans[:0]
+	for i, term := range q.terms {
+		if q.positive[i] && found[i] != termAbsent {
+			res.Spans = append(res.Spans, term.FindAll(line, q.overlapping)...)
+		}
+	}
+	sort.Slice(res.Spans, func(i, j int) bool {
+		return res.Spans[i].Start < res.Spans[j].Start
+	})
+	return true
+}
+
+// termResult caches the search of a term in the current line, so terms that
+// appear several times in the expression are searched once.
+type termResult int8
+
+const (
+	termUnknown termResult = iota
+	termPresent
+	termAbsent
+)
+
+func (e *queryExpr) eval(node *queryNode, line []byte, found []termResult) bool {
+	switch node.op {
+	case andOp:
+		for _, sub := range node.subs {
+			if !e.eval(sub, line, found) {
+				return false
+			}
+		}
+		return true
+	case orOp:
+		for _, sub := range node.subs {
+			if e.eval(sub, line, found) {
+				return true
+			}
+		}
+		return false
+	case notOp:
+		return !e.eval(node.subs[0], line, found)
+	}
+	if found[node.term] == termUnknown {
+		found[node.term] = termAbsent
+		if e.terms[node.term].search(line, 0) != -1 {
+			found[node.term] = termPresent
+		}
+	}
+	return found[node.term] == termPresent
+}
+
+func (q *FileQuery) maxMatchLen() int {
+	result := 0
+	for _, term := range q.terms {
+		if term.patternLen > result {
+			result = term.patternLen
+		}
+	}
+	return result
+}
+
+func (q *FileQuery) newFileState() fileState {
+	return &queryFileState{
+		queryExpr: q.queryExpr,
+		found:     make([]termResult, len(q.terms)),
+	}
+}
+
+// queryFileState knows which terms were seen in the file so far. A term that
+// hasn't been seen is termUnknown until the end of the file.
+type queryFileState struct {
+	*queryExpr
+	found []termResult
+}
+
+func (s *queryFileState) feed(line []byte) bool {
+	for i, term := range s.terms {
+		if s.found[i] != termPresent && term.search(line, 0) != -1 {
+			s.found[i] = termPresent
+		}
+	}
+	return s.partial(s.root) != termUnknown
+}
+
+func (s *queryFileState) matched() bool {
+	for i := range s.found {
+		if s.found[i] == termUnknown {
+			s.found[i] = termAbsent
+		}
+	}
+	return s.eval(s.root, nil, s.found)
+}
+
+// partial evaluates node while unseen terms may still appear later in the
+// file. It returns termPresent if node is true whatever the rest of the file
+// holds, termAbsent if it is false, and termUnknown otherwise.
+func (s *queryFileState) partial(node *queryNode) termResult {
+	switch node.op {
+	case andOp, orOp:
+		// AND is decided by a false operand, OR by a true one.
+		decisive, other := termAbsent, termPresent
+		if node.op == orOp {
+			decisive, other = termPresent, termAbsent
+		}
+		unknown := false
+		for _, sub := range node.subs {
+			switch s.partial(sub) {
+			case decisive:
+				return decisive
+			case termUnknown:
+				unknown = true
+			}
+		}
+		if unknown {
+			return termUnknown
+		}
+		return other
+	case notOp:
+		switch s.partial(node.subs[0]) {
+		case termPresent:
+			return termAbsent
+		case termAbsent:
+			return termPresent
+		}
+		return termUnknown
+	}
+	return s.found[node.term]
+}
+
+type queryToken struct {
+	text string
+	// quoted tokens are always terms, even if they look like operators.
+	quoted bool
+}
+
+func (t queryToken) is(keyword string) bool {
+	return !t.quoted && t.text == keyword
+}
+
+func tokenizeQuery(expr string) ([]queryToken, error) {
+	var tokens []queryToken
+	for i := 0; i < len(expr); {
+		switch c := expr[i]; {
+		case unicode.IsSpace(rune(c)):
+			i++
+		case c == '(' || c == ')':
+			tokens = append(tokens, queryToken{text: expr[i : i+1]})
+			i++
+		case c == '"':
+			var term strings.Builder
+			i++
+			for ; i < len(expr) && expr[i] != '"'; i++ {
+				if expr[i] == '\\' && i+1 < len(expr) {
+					i++
+				}
+				term.WriteByte(expr[i])
+			}
+			if i == len(expr) {
+				return nil, fmt.Errorf("%w: unterminated quote", ErrQuerySyntax)
+			}
+			tokens = append(tokens, queryToken{text: term.String(), quoted: true})
+			i++
+		default:
+			start := i
+			for i < len(expr) && !unicode.IsSpace(rune(expr[i])) && expr[i] != '(' && expr[i] != ')' && expr[i] != '"' {
+				i++
+			}
+			tokens = append(tokens, queryToken{text: expr[start:i]})
+		}
+	}
+	return tokens, nil
+}
+
+type queryParser struct {
+	tokens []queryToken
+	pos    int
+	// terms maps each distinct term to its index in Query.terms.
+	terms map[string]int
+}
+
+func (p *queryParser) peek() (queryToken, bool) {
+	if p.pos < len(p.tokens) {
+		return p.tokens[p.pos], true
+	}
+	return queryToken{}, false
+}
+
+func (p *queryParser) parseOr() (*queryNode, error) {
+	node, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	subs := []*queryNode{node}
+	for token, ok := p.peek(); ok && token.is("OR"); token, ok = p.peek() {
+		p.pos++
+		node, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		subs = append(subs, node)
+	}
+	if len(subs) == 1 {
+		return subs[0], nil
+	}
+	return &queryNode{op: orOp, subs: subs}, nil
+}
+
+func (p *queryParser) parseAnd() (*queryNode, error) {
+	node, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	subs := []*queryNode{node}
+	for token, ok := p.peek(); ok && !token.is("OR") && !token.is(")"); token, ok = p.peek() {
+		if token.is("AND") {
+			p.pos++
+		}
+		node, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		subs = append(subs, node)
+	}
+	if len(subs) == 1 {
+		return subs[0], nil
+	}
+	return &queryNode{op: andOp, subs: subs}, nil
+}
+
+func (p *queryParser) parseNot() (*queryNode, error) {
+	token, ok := p.peek()
+	if ok && token.is("NOT") {
+		p.pos++
+		node, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return &queryNode{op: notOp, subs: []*queryNode{node}}, nil
+	}
+	return p.parsePrimary()
+}
+
+func (p *queryParser) parsePrimary() (*queryNode, error) {
+	token, ok := p.peek()
+	switch {
+	case !ok:
+		return nil, fmt.Errorf("%w: unexpected end of query", ErrQuerySyntax)
+	case token.is("("):
+		p.pos++
+		node, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if token, ok := p.peek(); !ok || !token.is(")") {
+			return nil, fmt.Errorf("%w: missing closing parenthesis", ErrQuerySyntax)
+		}
+		p.pos++
+		return node, nil
+	case token.is(")") || token.is("AND") || token.is("OR") || token.text == "":
+		return nil, fmt.Errorf("%w: unexpected %q", ErrQuerySyntax, token.text)
+	}
+	p.pos++
+	term, ok := p.terms[token.text]
+	if !ok {
+		term = len(p.terms)
+		p.terms[token.text] = term
+	}
+	return &queryNode{op: termOp, term: term}, nil
+}
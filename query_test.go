@@ -0,0 +1,137 @@
+//go:build !time
+
+package grep_test
+
+import (
+	"errors"
+	"io"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/alex123012/go-grep"
+)
+
+func TestQuery(t *testing.T) {
+	fileName := writeTestFile(t, "query.txt", "kill the king\nkill the queen\nthe king and queen\nkill\nAND OR NOT\n")
+	testCases := []struct {
+		expr  string
+		lines []int
+	}{
+		{expr: "kill", lines: []int{1, 2, 4}},
+		{expr: "kill AND king", lines: []int{1}},
+		{expr: "kill king", lines: []int{1}},
+		{expr: "kill AND NOT king", lines: []int{2, 4}},
+		{expr: "king OR queen", lines: []int{1, 2, 3}},
+		{expr: "kill AND (king OR queen)", lines: []int{1, 2}},
+		{expr: "NOT (kill OR king)", lines: []int{5}},
+		{expr: "NOT NOT kill AND NOT the", lines: []int{4}},
+		{expr: `"the king" OR "NOT"`, lines: []int{1, 3, 5}},
+	}
+	for _, testCase := range testCases {
+		query, err := grep.MakeQuery(testCase.expr)
+		if err != nil {
+			t.Fatalf("Error in compiling %q: %v", testCase.expr, err)
+		}
+		fileMap, err := query.Search(fileName, false)
+		if err != nil {
+			t.Fatalf("Error in executing test on %s: %v", fileName, err)
+		}
+
+		v, f := fileMap.Get(fileName)
+		if !f || v.(grep.SyncMap).Len() != len(testCase.lines) {
+			t.Fatalf("Expected lines %v for %q, but got %+v", testCase.lines, testCase.expr, fileMap.GetStruct())
+		}
+		for _, number := range testCase.lines {
+			if _, f := v.(grep.SyncMap).Get(number); !f {
+				t.Fatalf("Expected line %d to match %q", number, testCase.expr)
+			}
+		}
+	}
+}
+
+func TestQuerySpans(t *testing.T) {
+	query, err := grep.MakeQuery("queen AND (the OR king) AND NOT kill")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fileName := writeTestFile(t, "query.txt", "the king and queen\n")
+	fileMap, err := query.Search(fileName, false)
+	if err != nil {
+		t.Fatalf("Error in executing test on %s: %v", fileName, err)
+	}
+	v, _ := fileMap.Get(fileName)
+	line, _ := v.(grep.SyncMap).Get(1)
+	spans := line.(*grep.Line).Spans
+	if len(spans) != 3 || spans[0].Start != 0 || spans[1].Start != 4 || spans[2].Start != 13 {
+		t.Fatalf("Expected spans of the, king and queen, but got %v", spans)
+	}
+}
+
+func TestQuerySyntax(t *testing.T) {
+	for _, expr := range []string{"", "kill AND", "(kill", "kill)", "OR kill", `"kill`, "NOT"} {
+		if _, err := grep.MakeQuery(expr); !errors.Is(err, grep.ErrQuerySyntax) {
+			t.Fatalf("Expected syntax error for %q, but got %v", expr, err)
+		}
+	}
+}
+
+func TestFileQuery(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"both.go":      "// Deprecated: use New.\nfunc Old() {}\n// TODO: remove\n",
+		"nolint.go":    "// Deprecated: use New.\n// TODO: remove\nfunc Old() {} //nolint\n",
+		"only_todo.go": "// TODO: remove\n",
+		"binary.bin":   "\x00\x01Deprecated TODO\n",
+	}
+	for name, content := range files {
+		writeFileInDir(t, dir, name, content)
+	}
+
+	query, err := grep.MakeFileQuery("Deprecated AND TODO AND NOT nolint")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fileMap, err := query.Search(dir, false)
+	if err != nil {
+		t.Fatalf("Error in executing test on %s: %v", dir, err)
+	}
+	result := fileMap.GetStruct()
+	if len(result) != 1 || filepath.Base(result[0].Name) != "both.go" || len(result[0].Lines) != 0 {
+		t.Fatalf("Expected only both.go without lines, but got %+v", result)
+	}
+
+	query.SetInvert(true)
+	fileMap, err = query.Search(dir, true)
+	if err != nil {
+		t.Fatalf("Error in executing test on %s: %v", dir, err)
+	}
+	if v := fileMap.Len(); v != 2 {
+		t.Fatalf("Expected 2 text files not selected by the query, but got %d", v)
+	}
+}
+
+func TestFileQueryDecidedEarly(t *testing.T) {
+	fileName := writeTestFile(t, "early.txt", "nolint\n"+strings.Repeat("TODO ", 1<<16)+"\n")
+	query, err := grep.MakeFileQuery("NOT nolint OR (TODO AND NOT nolint)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fileMap, err := query.Search(fileName, true)
+	if err != nil {
+		t.Fatalf("Error in executing test on %s: %v", fileName, err)
+	}
+	if v := fileMap.Len(); v != 0 {
+		t.Fatalf("Expected no files, but got %d", v)
+	}
+
+	// The rest of the input must not be read once "nolint" is seen.
+	r := io.MultiReader(strings.NewReader("nolint\nTODO\n"), &failingReader{t: t})
+	fileMap, err = query.SearchReader("early", r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v := fileMap.Len(); v != 0 {
+		t.Fatalf("Expected no files, but got %d", v)
+	}
+}
@@ -0,0 +1,230 @@
+package grep
+
+import "io"
+
+// chunkSize is the size of the buffer that files are read with.
+const chunkSize = 64 * 1024
+
+// lineSegment is a piece of a line passed to the callback of readLines. Lines
+// that fit into the buffer come in a single segment; longer lines may be split
+// into several segments that overlap each other.
+type lineSegment struct {
+	number int
+	// offset of text from the start of the line.
+	offset int
+	text   []byte
+	// last is set on the segment that ends the line.
+	last bool
+}
+
+// literalMatcher is implemented by finders whose matches are never longer
+// than maxMatchLen bytes and don't depend on the text around them. Their long
+// lines can be searched in overlapping segments instead of being read whole.
+// A zero maxMatchLen disables segmenting.
+type literalMatcher interface {
+	maxMatchLen() int
+}
+
+// readLines reads r in chunkSize chunks and calls fn for every record found by
+// records, numbering them, until fn returns false. A record that doesn't fit
+// into the buffer is passed in segments overlapping by overlap bytes, so no
+// match shorter than overlap+1 bytes is split between two segments. If overlap
+// is negative or too large, the buffer grows to hold the whole record instead.
+func readLines(r io.Reader, records splitter, overlap int, fn func(segment lineSegment) bool) error {
+	if overlap >= 0 && overlap < records.overlap() {
+		// A separator mustn't be split between segments either.
+		overlap = records.overlap()
+	}
+	if overlap >= chunkSize/2 {
+		overlap = -1
+	}
+	buf := make([]byte, chunkSize)
+	// buf[start:end] holds the data read but not passed to fn yet.
+	start, end := 0, 0
+	number, offset := 1, 0
+	eof := false
+	for {
+		for {
+			recordEnd, next, ok := records.split(buf[start:end], eof)
+			if !ok {
+				break
+			}
+			text := buf[start : start+recordEnd]
+			if offset > 0 || !records.skip(text) {
+				if !fn(lineSegment{number: number, offset: offset, text: text, last: true}) {
+					return nil
+				}
+				number++
+			}
+			start += next
+			offset = 0
+		}
+		if eof {
+			text := records.last(buf[start:end])
+			if offset > 0 || start < end && !records.skip(text) {
+				fn(lineSegment{number: number, offset: offset, text: text, last: true})
+			}
+			return nil
+		}
+
+		switch {
+		case start > 0:
+			end = copy(buf, buf[start:end])
+			start = 0
+		case end == len(buf) && overlap >= 0:
+			if !fn(lineSegment{number: number, offset: offset, text: buf[:end]}) {
+				return nil
+			}
+			offset += end - overlap
+			end = copy(buf, buf[end-overlap:end])
+		case end == len(buf):
+			grown := make([]byte, 2*len(buf))
+			copy(grown, buf)
+			buf = grown
+		}
+
+		n, err := r.Read(buf[end:])
+		end += n
+		if err == io.EOF {
+			eof = true
+		} else if err != nil {
+			return err
+		}
+	}
+}
+
+// splitLines calls fn for every record of buf, like readLines does for a
+// reader, until fn returns false. Every record is passed in a single segment.
+func splitLines(buf []byte, records splitter, fn func(segment lineSegment) bool) {
+	for number := 1; len(buf) > 0; {
+		text := buf
+		if end, next, ok := records.split(buf, true); ok {
+			text, buf = buf[:end], buf[next:]
+		} else {
+			text, buf = records.last(buf), nil
+		}
+		if records.skip(text) {
+			continue
+		}
+		if !fn(lineSegment{number: number, text: text, last: true}) {
+			return
+		}
+		number++
+	}
+}
+
+func dropCR(text []byte) []byte {
+	if len(text) > 0 && text[len(text)-1] == '\r' {
+		return text[:len(text)-1]
+	}
+	return text
+}
+
+// SetMaxLineText limits the text stored in Line.Text to the first max bytes
+// of each line. Spans still refer to the whole line. Zero, the default, keeps
+// whole lines. StringFinder, MultiFinder, BytesFinder and FileQuery search
+// long lines in fixed-size pieces, so with a limit their memory use doesn't
+// depend on the length of the lines.
+func (f *finder) SetMaxLineText(max int) {
+	f.maxLineText = max
+}
+
+// lineCollector assembles the results for the segments of one line.
+type lineCollector struct {
+	line    *Line
+	matched bool
+	// text holds the start of a segmented line, up to maxLineText.
+	text []byte
+	// seen is the length of the line read so far.
+	seen int
+	// end is the end of the last span kept. Unless overlapping matches are
+	// reported, the next one can't start before it.
+	end int
+}
+
+// add matches segment and reports, once the last segment of the line is
+// added, that the line is complete and whether it matched.
+func (c *lineCollector) add(f *finder, segment lineSegment) (complete, matched bool) {
+	if segment.offset == 0 {
+		c.line = &Line{Number: segment.number}
+		c.matched = false
+		c.text = c.text[:0]
+		c.seen, c.end = 0, 0
+	}
+	if segment.offset == 0 && segment.last {
+		// The whole line is in one segment.
+		matched := f.matcher.matchLine(segment.text, c.line)
+		if matched != f.invert {
+			c.line.Text = string(f.capText(segment.text, 0))
+		}
+		return true, matched
+	}
+
+	// Search on from the end of the last match, which the next one mustn't
+	// overlap.
+	from := 0
+	if !f.overlapping && c.end > segment.offset {
+		from = min(c.end-segment.offset, len(segment.text))
+	}
+	res := &Line{}
+	if f.matcher.matchLine(segment.text[from:], res) {
+		c.matched = true
+		for _, span := range res.Spans {
+			span.Start += segment.offset + from
+			span.End += segment.offset + from
+			// A match that ends in the overlap was found in the previous
+			// segment.
+			if segment.offset == 0 || span.End > c.seen {
+				c.line.Spans = append(c.line.Spans, span)
+				c.end = span.End
+			}
+		}
+		c.line.Patterns = mergePatterns(c.line.Patterns, res.Patterns)
+	}
+	// Skip the overlap with the previous segment.
+	if skip := c.seen - segment.offset; skip < len(segment.text) {
+		c.text = append(c.text, f.capText(segment.text[skip:], c.seen)...)
+		c.seen = segment.offset + len(segment.text)
+	}
+	if segment.last {
+		// The carriage return before the newline, dropped from the last
+		// segment, may have been passed in the previous one.
+		if end := segment.offset + len(segment.text); end < len(c.text) {
+			c.text = c.text[:end]
+		}
+		c.line.Text = string(c.text)
+	}
+	return segment.last, c.matched
+}
+
+// capText returns the part of text, which starts at offset in its line, that
+// is stored in Line.Text.
+func (f *finder) capText(text []byte, offset int) []byte {
+	switch {
+	case f.onlyFiles:
+		return nil
+	case f.maxLineText <= 0:
+		return text
+	case offset >= f.maxLineText:
+		return nil
+	case offset+len(text) > f.maxLineText:
+		return text[:f.maxLineText-offset]
+	}
+	return text
+}
+
+func mergePatterns(patterns, more []string) []string {
+	for _, pattern := range more {
+		found := false
+		for _, p := range patterns {
+			if p == pattern {
+				found = true
+				break
+			}
+		}
+		if !found {
+			patterns = append(patterns, pattern)
+		}
+	}
+	return patterns
+}
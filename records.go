@@ -0,0 +1,162 @@
+package grep
+
+import "bytes"
+
+// RecordMode tells how files are split into the records that Search matches
+// one by one. Line.Number is then the number of the record, and Line.Text
+// holds the record without its separator.
+type RecordMode int
+
+const (
+	// RecordLines splits at newlines, dropping a carriage return before
+	// them. It is the default.
+	RecordLines RecordMode = iota
+	// RecordAnyNewline splits at CRLF, LF and also a lone CR, as in classic
+	// Mac OS files.
+	RecordAnyNewline
+	// RecordNUL splits at NUL bytes, like the output of find -print0.
+	RecordNUL
+	// RecordParagraphs splits at blank lines. The lines of a paragraph are
+	// kept in Line.Text, separated by newlines.
+	RecordParagraphs
+	// RecordSeparator splits at the separator set with SetRecordSeparator,
+	// or at newlines if there is none.
+	RecordSeparator
+)
+
+// SetRecordMode sets how files are split into records. Multiline and binary
+// search, see SetMultiline and SetBinarySearch, ignore the mode.
+func (f *finder) SetRecordMode(mode RecordMode) {
+	switch {
+	case mode == RecordNUL:
+		f.records = splitter{mode: mode, separator: []byte{0}}
+	case mode != RecordSeparator:
+		f.records = splitter{mode: mode}
+	case len(f.records.separator) > 0:
+		// Keep the separator set before.
+		f.records.mode = mode
+	default:
+		f.records = splitter{}
+	}
+}
+
+// SetRecordSeparator makes Search split files at every occurrence of
+// separator, as RecordSeparator. Use "\n" to keep carriage returns at the end
+// of lines.
+func (f *finder) SetRecordSeparator(separator string) {
+	if separator == "" {
+		f.SetRecordMode(RecordLines)
+		return
+	}
+	f.records = splitter{mode: RecordSeparator, separator: []byte(separator)}
+}
+
+// splitter finds the records of a text.
+type splitter struct {
+	mode RecordMode
+	// separator is used by RecordNUL and RecordSeparator.
+	separator []byte
+}
+
+// split finds the first record in data. The record is data[:end] and the next
+// one starts at next. ok is false if there is no separator in data or, unless
+// atEOF, it may continue past data.
+func (s splitter) split(data []byte, atEOF bool) (end, next int, ok bool) {
+	switch s.mode {
+	case RecordLines:
+		i := bytes.IndexByte(data, '\n')
+		if i == -1 {
+			return 0, 0, false
+		}
+		return len(dropCR(data[:i])), i + 1, true
+	case RecordAnyNewline:
+		i := bytes.IndexAny(data, "\r\n")
+		switch {
+		case i == -1:
+			return 0, 0, false
+		case data[i] == '\n':
+			return i, i + 1, true
+		case i+1 < len(data):
+			if data[i+1] == '\n' {
+				return i, i + 2, true
+			}
+			return i, i + 1, true
+		}
+		return i, i + 1, atEOF
+	case RecordParagraphs:
+		return s.splitParagraph(data, atEOF)
+	}
+	i := bytes.Index(data, s.separator)
+	if i == -1 {
+		return 0, 0, false
+	}
+	return i, i + len(s.separator), true
+}
+
+func (s splitter) splitParagraph(data []byte, atEOF bool) (end, next int, ok bool) {
+	for pos := 0; ; {
+		i := bytes.IndexByte(data[pos:], '\n')
+		if i == -1 {
+			return 0, 0, false
+		}
+		i += pos
+
+		// Skip the blank lines after the newline.
+		blank := 0
+		next = i + 1
+		for {
+			j := next
+			if j < len(data) && data[j] == '\r' {
+				j++
+			}
+			if j == len(data) {
+				if !atEOF {
+					// More blank lines may follow.
+					return 0, 0, false
+				}
+				break
+			}
+			if data[j] != '\n' {
+				break
+			}
+			blank++
+			next = j + 1
+		}
+		if blank > 0 {
+			return len(dropCR(data[:i])), next, true
+		}
+		pos = i + 1
+	}
+}
+
+// overlap returns how many bytes the segments of a long record must overlap
+// so that no separator, with the carriage return dropped before it, is split
+// between two of them.
+func (s splitter) overlap() int {
+	switch s.mode {
+	case RecordLines, RecordAnyNewline:
+		return 1
+	case RecordParagraphs:
+		// A carriage return, a newline and a blank line.
+		return 3
+	}
+	return len(s.separator) - 1
+}
+
+// last returns the text of the final record, which has no separator after
+// it.
+func (s splitter) last(text []byte) []byte {
+	switch s.mode {
+	case RecordLines:
+		return dropCR(text)
+	case RecordParagraphs:
+		return bytes.TrimRight(text, "\r\n")
+	}
+	return text
+}
+
+// skip reports whether text, a whole record, isn't one, like the blank lines
+// at the start of a file in paragraph mode.
+func (s splitter) skip(text []byte) bool {
+	return s.mode == RecordParagraphs && len(text) == 0
+}
@@ -0,0 +1,144 @@
+//go:build !time
+
+package grep_test
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/alex123012/go-grep"
+)
+
+func TestRecordModes(t *testing.T) {
+	testCases := []struct {
+		name      string
+		content   string
+		mode      grep.RecordMode
+		separator string
+		expected  map[int]string
+	}{
+		{
+			name:     "lines",
+			content:  "kill one\r\nother\nkill two\r\n",
+			mode:     grep.RecordLines,
+			expected: map[int]string{1: "kill one", 3: "kill two"},
+		},
+		{
+			name:     "classic mac",
+			content:  "kill one\rother\r\nkill two\rkill three\n\nkill four",
+			mode:     grep.RecordAnyNewline,
+			expected: map[int]string{1: "kill one", 3: "kill two", 4: "kill three", 6: "kill four"},
+		},
+		{
+			name:     "nul",
+			content:  "./a\x00./kill\nme\x00./b/kill\x00",
+			mode:     grep.RecordNUL,
+			expected: map[int]string{2: "./kill\nme", 3: "./b/kill"},
+		},
+		{
+			name:     "paragraphs",
+			content:  "\n\nfirst paragraph\nwith kill\n\n\r\n\nsecond\nparagraph\n\nkill\nlast\n",
+			mode:     grep.RecordParagraphs,
+			expected: map[int]string{1: "first paragraph\nwith kill", 3: "kill\nlast"},
+		},
+		{
+			name:      "separator",
+			content:   "kill--8<--nothing--8<--more kill--8<",
+			separator: "--8<--",
+			expected:  map[int]string{1: "kill", 3: "more kill--8<"},
+		},
+		{
+			name:      "raw newline",
+			content:   "kill\r\nno\n",
+			separator: "\n",
+			expected:  map[int]string{1: "kill\r"},
+		},
+	}
+	for _, testCase := range testCases {
+		fileName := writeTestFile(t, "records.txt", testCase.content)
+		for _, threshold := range []int64{0, 1} {
+			patternSearch := grep.MakeStringFinder("kill")
+			patternSearch.SetMmapThreshold(threshold)
+			if testCase.separator != "" {
+				patternSearch.SetRecordSeparator(testCase.separator)
+			} else {
+				patternSearch.SetRecordMode(testCase.mode)
+			}
+			fileMap, err := patternSearch.Search(fileName, false)
+			if err != nil {
+				t.Fatal(err)
+			}
+			result := map[int]string{}
+			for number, line := range searchResults(fileMap)[fileName] {
+				result[number] = line.Text
+			}
+			if !reflect.DeepEqual(result, testCase.expected) {
+				t.Errorf("%s with mmap threshold %d: expected %q, but got %q", testCase.name, threshold, testCase.expected, result)
+			}
+		}
+	}
+}
+
+func TestRecordSeparatorLongRecords(t *testing.T) {
+	record := strings.Repeat("x", 100000) + "kill" + strings.Repeat("y", 100000)
+	content := record + "<SEP>" + strings.Repeat("z", 70000) + "<SEP>" + record
+	fileName := writeTestFile(t, "long.txt", content)
+
+	patternSearch := grep.MakeStringFinder("kill")
+	patternSearch.SetRecordSeparator("<SEP>")
+	patternSearch.SetMaxLineText(10)
+	fileMap, err := patternSearch.Search(fileName, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	result := searchResults(fileMap)[fileName]
+	if len(result) != 2 || result[1].Column != 100001 || result[3].Column != 100001 {
+		t.Fatalf("Expected records 1 and 3, but got %+v", result)
+	}
+}
+
+func TestRecordModesWholeLine(t *testing.T) {
+	testCases := []struct {
+		mode     grep.RecordMode
+		content  string
+		expected map[int]string
+	}{
+		{mode: grep.RecordNUL, content: "x\nid\x00other\x00id\x00", expected: map[int]string{3: "id"}},
+		{mode: grep.RecordParagraphs, content: "x\nid\n\nid\n\nid\nx\n", expected: map[int]string{2: "id"}},
+		{mode: grep.RecordLines, content: "x\r\nid\r\n", expected: map[int]string{2: "id"}},
+	}
+	for _, testCase := range testCases {
+		fileName := writeTestFile(t, "records.txt", testCase.content)
+		for _, threshold := range []int64{0, 1} {
+			patternSearch := grep.MakeStringFinder("id")
+			patternSearch.SetBoundary(grep.WholeLine)
+			patternSearch.SetRecordMode(testCase.mode)
+			patternSearch.SetMmapThreshold(threshold)
+			fileMap, err := patternSearch.Search(fileName, false)
+			if err != nil {
+				t.Fatal(err)
+			}
+			result := map[int]string{}
+			for number, line := range searchResults(fileMap)[fileName] {
+				result[number] = line.Text
+			}
+			if !reflect.DeepEqual(result, testCase.expected) {
+				t.Errorf("Mode %d with mmap threshold %d: expected %q, but got %q", testCase.mode, threshold, testCase.expected, result)
+			}
+		}
+	}
+
+	// In multiline mode the newlines around a match are line boundaries.
+	fileName := writeTestFile(t, "multiline.txt", "x\nid\ny\n")
+	patternSearch := grep.MakeStringFinder("id")
+	patternSearch.SetBoundary(grep.WholeLine)
+	patternSearch.SetMultiline(true)
+	fileMap, err := patternSearch.Search(fileName, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result := searchResults(fileMap)[fileName]; len(result) != 1 || result[2].Text != "id" {
+		t.Errorf("Expected line 2 in multiline mode, but got %+v", result)
+	}
+}
@@ -0,0 +1,187 @@
+package grep
+
+import (
+	"regexp"
+	"regexp/syntax"
+	"unicode/utf8"
+)
+
+// maxLiterals is the largest number of alternative literals that are still
+// worth checking with Boyer-Moore before falling back to the regexp engine.
+const maxLiterals = 8
+
+// RegexpFinder finds lines matching a regular expression in RE2 syntax. Literal
+// substrings that every match must contain are pulled out of the expression
+// and searched with the StringFinder tables first, so lines that can't match
+// never reach the regexp engine.
+type RegexpFinder struct {
+	re *regexp.Regexp
+	// lineRe is re with the m flag, for multiline mode.
+	lineRe *regexp.Regexp
+
+	// literals holds the prefilter: a line can only match if it contains at
+	// least one of them. It is empty when no such literal could be extracted.
+	literals []*StringFinder
+
+	*finder
+}
+
+func MakeRegexpFinder(expr string) (*RegexpFinder, error) {
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return nil, err
+	}
+	parsed, err := syntax.Parse(expr, syntax.Perl)
+	if err != nil {
+		return nil, err
+	}
+
+	lineRe, err := regexp.Compile("(?m)" + expr)
+	if err != nil {
+		return nil, err
+	}
+
+	f := &RegexpFinder{re: re, lineRe: lineRe}
+	for _, literal := range requiredLiterals(parsed.Simplify()) {
+		f.literals = append(f.literals, makeStringSearcher(literal))
+	}
+	f.finder = makeFinder(f)
+	return f, nil
+}
+
+func (f *RegexpFinder) matchLine(line []byte, res *Line) bool {
+	res.Spans = f.FindAll(line, f.overlapping)
+	return len(res.Spans) > 0
+}
+
+// FindAll returns the spans of all leftmost-first matches in text. With
+// overlapping set the next match is looked for from the rune after the start
+// of the previous one instead of from its end. In multiline mode ^ and $ match
+// at every line of text.
+func (f *RegexpFinder) FindAll(text []byte, overlapping bool) []Span {
+	if len(f.literals) > 0 && !f.hasLiteral(text) {
+		return nil
+	}
+	re := f.re
+	if f.multiline {
+		re = f.lineRe
+	}
+	if !overlapping {
+		var spans []Span
+		for _, loc := range re.FindAllIndex(text, -1) {
+			spans = append(spans, Span{Start: loc[0], End: loc[1]})
+		}
+		return spans
+	}
+
+	var spans []Span
+	for pos := 0; pos <= len(text); {
+		loc := re.FindIndex(text[pos:])
+		if loc == nil {
+			break
+		}
+		start := pos + loc[0]
+		spans = append(spans, Span{Start: start, End: pos + loc[1]})
+		if start == len(text) {
+			break
+		}
+		_, size := utf8.DecodeRune(text[start:])
+		pos = start + size
+	}
+	return spans
+}
+
+func (f *RegexpFinder) hasLiteral(line []byte) bool {
+	for _, literal := range f.literals {
+		if literal.search(line, 0) != -1 {
+			return true
+		}
+	}
+	return false
+}
+
+// requiredLiterals returns a set of literals such that any text matching re
+// contains at least one of them. A nil result means that nothing useful could
+// be extracted and every line has to be checked by the regexp engine.
+func requiredLiterals(re *syntax.Regexp) []string {
+	switch re.Op {
+	case syntax.OpLiteral:
+		if re.Flags&syntax.FoldCase != 0 {
+			return nil
+		}
+		return []string{string(re.Rune)}
+	case syntax.OpCapture, syntax.OpPlus:
+		return requiredLiterals(re.Sub[0])
+	case syntax.OpRepeat:
+		if re.Min < 1 {
+			return nil
+		}
+		return requiredLiterals(re.Sub[0])
+	case syntax.OpConcat:
+		return concatLiterals(re.Sub)
+	case syntax.OpAlternate:
+		var result []string
+		for _, sub := range re.Sub {
+			literals := requiredLiterals(sub)
+			if literals == nil {
+				return nil
+			}
+			result = append(result, literals...)
+		}
+		if len(result) > maxLiterals {
+			return nil
+		}
+		return result
+	}
+	return nil
+}
+
+// concatLiterals joins runs of adjacent literals in a concatenation and
+// returns the best literal set found among the runs and the other
+// subexpressions.
+func concatLiterals(subs []*syntax.Regexp) []string {
+	var best []string
+	var run []rune
+	flush := func() {
+		if len(run) > 0 {
+			best = betterLiterals(best, []string{string(run)})
+			run = nil
+		}
+	}
+	for _, sub := range subs {
+		if sub.Op == syntax.OpLiteral && sub.Flags&syntax.FoldCase == 0 {
+			run = append(run, sub.Rune...)
+			continue
+		}
+		flush()
+		best = betterLiterals(best, requiredLiterals(sub))
+	}
+	flush()
+	return best
+}
+
+// betterLiterals prefers the set whose shortest literal is longer, since
+// Boyer-Moore skips further with longer patterns, and then the smaller set.
+func betterLiterals(a, b []string) []string {
+	if a == nil {
+		return b
+	}
+	if b == nil {
+		return a
+	}
+	minA, minB := shortest(a), shortest(b)
+	if minB > minA || (minB == minA && len(b) < len(a)) {
+		return b
+	}
+	return a
+}
+
+func shortest(literals []string) int {
+	result := len(literals[0])
+	for _, literal := range literals[1:] {
+		if len(literal) < result {
+			result = len(literal)
+		}
+	}
+	return result
+}
@@ -0,0 +1,71 @@
+//go:build !time
+
+package grep_test
+
+import (
+	"testing"
+
+	"github.com/alex123012/go-grep"
+)
+
+func TestRegexpFiles(t *testing.T) {
+	testCases := []TestCase{
+		{
+			fileName:     "./test_cases/test_dir/test_short_text_file.txt",
+			pattern:      "^A0A[0-9]",
+			grepCount:    13,
+			grepLastLine: 20,
+		},
+		{
+			fileName:     "./test_cases/test_dir/test_short_text_file.txt",
+			pattern:      "Q9|P0",
+			grepCount:    26,
+			grepLastLine: 108,
+		},
+	}
+	for _, testCase := range testCases {
+		fileMap := testRegexpFile(testCase, t)
+		v, f := fileMap.Get(testCase.fileName)
+		if len := v.(grep.SyncMap).Len(); len != testCase.grepCount || !f {
+			t.Fatalf("Expected %d in RegexpFinder.Search for %q, but got %d", testCase.grepCount, testCase.pattern, len)
+		}
+
+		testCase.onlyFiles = true
+		fileMap = testRegexpFile(testCase, t)
+		v, f = fileMap.Get(testCase.fileName)
+		if v, fv := v.(grep.SyncMap).Get(testCase.grepLastLine); v != testCase.grepLastLine || !f || !fv {
+			t.Fatalf("Expected %d in RegexpFinder.Search for %q, but got %d", testCase.grepLastLine, testCase.pattern, v)
+		}
+	}
+}
+
+func TestRegexpNoLiterals(t *testing.T) {
+	testCase := TestCase{
+		fileName:  "./test_cases/test_dir/test_short_text_file.txt",
+		pattern:   "^[A-Z][0-9]{5}$",
+		grepCount: 49,
+	}
+	fileMap := testRegexpFile(testCase, t)
+	v, f := fileMap.Get(testCase.fileName)
+	if len := v.(grep.SyncMap).Len(); len != testCase.grepCount || !f {
+		t.Fatalf("Expected %d in RegexpFinder.Search for %q, but got %d", testCase.grepCount, testCase.pattern, len)
+	}
+}
+
+func TestRegexpInvalid(t *testing.T) {
+	if _, err := grep.MakeRegexpFinder("A0A("); err == nil {
+		t.Fatal("Expected error for invalid regular expression")
+	}
+}
+
+func testRegexpFile(testCase TestCase, t *testing.T) *grep.MapFiles {
+	patternSearch, err := grep.MakeRegexpFinder(testCase.pattern)
+	if err != nil {
+		t.Fatalf("Error in compiling %q: %v", testCase.pattern, err)
+	}
+	fileMap, err := patternSearch.Search(testCase.fileName, testCase.onlyFiles)
+	if err != nil {
+		t.Errorf("Error in executing test on %s: %v", testCase.fileName, err)
+	}
+	return fileMap
+}
@@ -22,20 +22,51 @@ type GetMapper interface {
 type File struct {
 	Name  string
 	Lines []*Line
+
+	// Binary is set for a binary file that matches, see BinaryMatches.
+	Binary bool `json:",omitempty"`
 }
 type Line struct {
 	Number int
 	Text   string
+
+	// Offset is the byte offset of a match found by binary search, which
+	// reports no line numbers.
+	Offset int64 `json:",omitempty"`
+
+	// EndNumber is the last line of a multi-line match. Text then holds all
+	// the lines from Number to EndNumber, separated by newlines.
+	EndNumber int `json:",omitempty"`
+
+	// Column is the 1-based byte column of the first match in the line.
+	Column int `json:",omitempty"`
+	// Spans holds the byte ranges of all matches in the line.
+	Spans []Span `json:",omitempty"`
+	// Patterns holds the patterns found in the line by a MultiFinder.
+	Patterns []string `json:",omitempty"`
+}
+
+// Span is a match in a line: Text[Start:End].
+type Span struct {
+	Start int
+	End   int
+
+	// Distance is the edit distance of an ApproxFinder match.
+	Distance int `json:",omitempty"`
 }
 type MapFiles struct {
 	mux     *sync.RWMutex
 	storage map[string]SyncMap
+	binary  map[string]bool
+	errors  map[string]error
 }
 
 func MakeMapFiles() *MapFiles {
 	return &MapFiles{
 		mux:     &sync.RWMutex{},
 		storage: make(map[string]SyncMap),
+		binary:  make(map[string]bool),
+		errors:  make(map[string]error),
 	}
 }
 
@@ -43,15 +74,12 @@ func (m *MapFiles) GetStruct() []*File {
 	result := []*File{}
 	m.Range(func(key interface{}, value interface{}) bool {
 		file := &File{
-			Name:  key.(string),
-			Lines: []*Line{},
+			Name:   key.(string),
+			Lines:  []*Line{},
+			Binary: m.Binary(key.(string)),
 		}
 		value.(SyncMap).Range(func(key, value interface{}) bool {
-			line := &Line{
-				Number: key.(int),
-				Text:   value.(string),
-			}
-			file.Lines = append(file.Lines, line)
+			file.Lines = append(file.Lines, value.(*Line))
 			return true
 		})
 		result = append(result, file)
@@ -63,6 +91,7 @@ func (m *MapFiles) GetStruct() []*File {
 func (m *MapFiles) Delete(key any) {
 	m.mux.Lock()
 	delete(m.storage, key.(string))
+	delete(m.binary, key.(string))
 	m.mux.Unlock()
 }
 func (m *MapFiles) Get(key any) (value any, ok bool) {
@@ -84,6 +113,42 @@ func (m *MapFiles) Put(key, value any) {
 	m.mux.Unlock()
 }
 
+// putBinary stores name as a binary file that matches, without lines.
+func (m *MapFiles) putBinary(name string) {
+	m.mux.Lock()
+	m.storage[name] = MakeOnlyFiles()
+	m.binary[name] = true
+	m.mux.Unlock()
+}
+
+// Binary reports whether name is a binary file stored without its lines, see
+// BinaryMatches.
+func (m *MapFiles) Binary(name string) bool {
+	m.mux.RLock()
+	defer m.mux.RUnlock()
+	return m.binary[name]
+}
+
+// putError stores the error that kept name from being searched.
+func (m *MapFiles) putError(name string, err error) {
+	m.mux.Lock()
+	m.errors[name] = err
+	m.mux.Unlock()
+}
+
+// Errors returns the errors that kept files from being searched, like broken
+// symbolic links or compressed files over the decompressed size limit, by the
+// names of the files.
+func (m *MapFiles) Errors() map[string]error {
+	m.mux.RLock()
+	defer m.mux.RUnlock()
+	errors := make(map[string]error, len(m.errors))
+	for name, err := range m.errors {
+		errors[name] = err
+	}
+	return errors
+}
+
 func (m *MapFiles) Len() int {
 	m.mux.RLock()
 	defer m.mux.RUnlock()
@@ -126,18 +191,20 @@ func (o *onlyFiles) Len() int {
 }
 
 func (o *onlyFiles) Range(f func(key, value any) bool) {
-	f((int)(*o), "")
+	if line := o.Len(); line > 0 {
+		f(line, &Line{Number: line})
+	}
 }
 
 type linesWithText struct {
 	mux     *sync.RWMutex
-	storage map[int]string
+	storage map[int]*Line
 }
 
 func MakeLinesWithText() SyncMap {
 	return &linesWithText{
 		mux:     &sync.RWMutex{},
-		storage: make(map[int]string),
+		storage: make(map[int]*Line),
 	}
 }
 
@@ -160,9 +227,18 @@ func (l *linesWithText) Pop(key any) (value any, loaded bool) {
 	return v, f
 }
 
+// Put stores value for the line number key. The value is either the *Line
+// itself or the raw line text.
 func (l *linesWithText) Put(key, value any) {
+	line, ok := value.(*Line)
+	if !ok {
+		line = &Line{
+			Number: key.(int),
+			Text:   string(value.([]byte)),
+		}
+	}
 	l.mux.Lock()
-	l.storage[key.(int)] = string(value.([]byte))
+	l.storage[key.(int)] = line
 	l.mux.Unlock()
 }
 
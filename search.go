@@ -0,0 +1,487 @@
+package grep
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"io/fs"
+
+	"golang.org/x/sync/errgroup"
+)
+
+const GouroutinesLimit = 512
+
+// lineMatcher is implemented by every finder and reports whether a single
+// line of text matches its pattern. Details of the match, if the finder has
+// any, are recorded in res.
+type lineMatcher interface {
+	matchLine(line []byte, res *Line) bool
+}
+
+// fileMatcher is implemented by finders that select whole files instead of
+// lines.
+type fileMatcher interface {
+	newFileState() fileState
+}
+
+// fileState tracks the lines of one file seen by a fileMatcher.
+type fileState interface {
+	// feed adds the next line of the file and reports whether the outcome
+	// is decided, so the rest of the file doesn't need to be read.
+	feed(line []byte) bool
+	// matched reports whether the file is selected, given that no more
+	// lines follow.
+	matched() bool
+}
+
+// finder walks the file tree and collects the lines accepted by matcher, or
+// the files accepted by fileMatcher if it is set. It is embedded by every
+// exported finder, so they share the same Search contract.
+type finder struct {
+	matcher     lineMatcher
+	fileMatcher fileMatcher
+
+	// overlapping makes the stored Line.Spans include overlapping matches.
+	overlapping bool
+
+	// invert selects the lines, or with onlyFiles the files, that don't
+	// match.
+	invert    bool
+	onlyFiles bool
+
+	// multiline matches patterns against whole files.
+	multiline bool
+	// binarySearch matches patterns against raw file bytes.
+	binarySearch bool
+
+	// binaryMode tells what to do with the files that binaryWindow, or the
+	// whole file if it isn't positive, shows to be binary.
+	binaryMode   BinaryMode
+	binaryWindow int
+
+	encoding Encoding
+	// records splits files into the records matched one by one.
+	records splitter
+
+	// maxLineText limits the stored text of each line, if positive.
+	maxLineText int
+
+	// maxDecompressed limits the decompressed size of compressed files,
+	// which are searched only if it is positive.
+	maxDecompressed int64
+
+	// archiveDepth is how deep Search descends into nested archives, if
+	// positive, and maxArchiveSize limits the bytes extracted from each.
+	archiveDepth   int
+	maxArchiveSize int64
+
+	// mmapThreshold is the size from which files are mapped into memory,
+	// if positive.
+	mmapThreshold int64
+
+	// ignore is the sources of the patterns of files skipped by Search.
+	ignore IgnoreSource
+	// include and exclude are the globs, and types and notTypes the file
+	// types, of the files searched and skipped.
+	include, exclude []ignoreRule
+	types, notTypes  []FileType
+
+	// maxDepth limits the depth of the walk, if positive.
+	maxDepth int
+	// hidden walks the files and directories whose names start with a dot.
+	hidden bool
+	// oneFileSystem skips the directories on other devices than the root.
+	oneFileSystem bool
+	symlinks      SymlinkMode
+
+	mapMaker func() SyncMap
+
+	gouroutinesLimit int
+	errGroup         *errgroup.Group
+	mapFiles         *MapFiles
+}
+
+func makeFinder(matcher lineMatcher) *finder {
+	f := &finder{
+		matcher:          matcher,
+		binaryWindow:     DefaultBinaryWindow,
+		mmapThreshold:    DefaultMmapThreshold,
+		ignore:           IgnoreAll,
+		gouroutinesLimit: GouroutinesLimit,
+	}
+	return f
+}
+
+func makeFileFinder(matcher fileMatcher) *finder {
+	f := makeFinder(nil)
+	f.fileMatcher = matcher
+	return f
+}
+
+// fileResult holds what Search selects in one file. It is stored in MapFiles
+// only once the whole file is searched, so a search that is started over, like
+// a mapped read falling back to buffered reads, leaves no partial results.
+type fileResult struct {
+	lines    SyncMap
+	selected bool
+	// binary is set if the file is found to be binary.
+	binary bool
+
+	// emit, if set, is called with every selected line instead of storing
+	// it. The first error it returns is kept in err and stops the search.
+	emit func(line *Line) error
+	err  error
+}
+
+func (f *finder) newResult() *fileResult {
+	return &fileResult{lines: f.mapMaker()}
+}
+
+// put selects the file and stores line under number, which is the line number
+// or, in binary search, the match number.
+func (r *fileResult) put(number int, line *Line) {
+	if r.emit != nil {
+		if r.binary {
+			line.Text = ""
+		}
+		if r.err == nil {
+			r.err = r.emit(line)
+		}
+		return
+	}
+	r.lines.Put(number, line)
+	r.selected = true
+}
+
+func (f *finder) commit(file string, res *fileResult) {
+	switch {
+	case !res.selected || res.binary && f.binaryMode == BinarySkip:
+	case res.binary:
+		f.mapFiles.putBinary(file)
+	default:
+		f.mapFiles.Put(file, res.lines)
+	}
+}
+
+// lineScanner calls fn with the segments of every line of a file until fn
+// returns false.
+type lineScanner func(overlap int, fn func(segment lineSegment) bool) error
+
+// scanLines returns a lineScanner for a file read from r or, if r is nil,
+// mapped into buf. A file read from r is checked for binary data as it is
+// read; once it is found binary, res.binary is set and, if binary files are
+// skipped, fn isn't called any more.
+func (f *finder) scanLines(r io.Reader, buf []byte, res *fileResult) lineScanner {
+	return func(overlap int, fn func(segment lineSegment) bool) error {
+		if r == nil {
+			splitLines(buf, f.records, fn)
+			return nil
+		}
+		detector := f.newBinaryDetector()
+		return readLines(r, f.records, overlap, func(segment lineSegment) bool {
+			if !res.binary && detector.check(segment) {
+				res.binary = true
+				if f.binaryMode == BinarySkip {
+					return false
+				}
+			}
+			return fn(segment)
+		})
+	}
+}
+
+// overlap returns how much consecutive segments of a long line must overlap
+// for the matcher, or -1 if the line must be read whole.
+func (f *finder) overlap(matcher interface{}) int {
+	if m, ok := matcher.(literalMatcher); ok && m.maxMatchLen() > 0 {
+		return m.maxMatchLen() - 1
+	}
+	return -1
+}
+
+// patternMatch searches the file open in fsys and stores what it selects
+// under name, which differs from open for the files reached through a
+// symbolic link.
+func (f *finder) patternMatch(fsys fs.FS, open, name string, byShebang func(interpreter string) bool) error {
+	openFile, err := fsys.Open(open)
+	if err != nil {
+		return err
+	}
+	defer openFile.Close()
+
+	if byShebang == nil && f.archiveDepth <= 0 {
+		return f.fileError(name, f.contentMatch(name, openFile, openFile))
+	}
+	br := bufio.NewReader(openFile)
+	if byShebang != nil {
+		start, _ := br.Peek(shebangLength)
+		if !byShebang(interpreter(start)) {
+			return nil
+		}
+	}
+	if f.archiveDepth > 0 {
+		if kind := detectArchive(br); kind != noArchive {
+			left := f.maxArchiveSize
+			return f.fileError(name, f.archiveMatch(name, openFile, br, kind, 1, &left))
+		}
+	}
+	return f.fileError(name, f.contentMatch(name, openFile, br))
+}
+
+// fileError stores err in MapFiles.Errors under name and returns nil if it
+// is an error of the file alone, like a size limit or corrupt content, so
+// only the search of that file stops. Other errors are returned as they are.
+func (f *finder) fileError(name string, err error) error {
+	var content contentError
+	if errors.Is(err, ErrDecompressedSize) || errors.Is(err, ErrArchiveSize) || errors.As(err, &content) {
+		f.mapFiles.putError(name, err)
+		return nil
+	}
+	return err
+}
+
+// contentMatch searches the file name read from r and stores what it
+// selects. file, if name is not in an archive, is the file r reads from,
+// which may be mapped into memory instead.
+func (f *finder) contentMatch(name string, file fs.File, r io.Reader) error {
+	r, converted, err := f.convert(r)
+	if err != nil {
+		return err
+	}
+	res := f.newResult()
+	mapped := false
+	if file != nil && !converted {
+		mapped, err = f.withMapping(file, func(buf []byte) error {
+			return f.matchContent(nil, buf, res)
+		})
+	}
+	if !mapped {
+		res = f.newResult()
+		err = f.matchContent(r, nil, res)
+	}
+	if err != nil {
+		return err
+	}
+	f.commit(name, res)
+	return nil
+}
+
+// convert returns a reader of the content of r to search, and converted set
+// if it differs from what r reads, because r is compressed or needs decoding.
+func (f *finder) convert(r io.Reader) (_ io.Reader, converted bool, err error) {
+	r, compressed, err := f.decompress(r)
+	if err != nil {
+		return nil, false, err
+	}
+	r, decoded := f.decode(r)
+	return r, compressed || decoded, nil
+}
+
+// sniffMagic returns r buffered, with its first n bytes or, if they start one
+// of the longer magic numbers, as many as that one has. Only the bytes needed
+// are peeked at, so streams aren't blocked on.
+func sniffMagic(r io.Reader, n int, longer ...[]byte) (*bufio.Reader, []byte) {
+	br := bufio.NewReader(r)
+	magic, _ := br.Peek(n)
+	for _, m := range longer {
+		if len(m) > len(magic) && bytes.HasPrefix(m, magic) {
+			magic, _ = br.Peek(len(m))
+		}
+	}
+	return br, magic
+}
+
+// matchContent searches a file read from r or, if r is nil, mapped into buf,
+// and records what it selects in res.
+func (f *finder) matchContent(r io.Reader, buf []byte, res *fileResult) error {
+	finder, whole := f.matcher.(spanFinder)
+	whole = whole && f.fileMatcher == nil && (f.binarySearch || f.multiline)
+	if whole && f.binarySearch {
+		return f.binaryMatch(r, buf, finder, res)
+	}
+	if whole && r != nil {
+		var err error
+		if buf, err = io.ReadAll(r); err != nil {
+			return err
+		}
+		r = nil
+	}
+	if r == nil && !f.binarySearch {
+		res.binary = f.bufferBinary(buf)
+		if res.binary && f.binaryMode == BinarySkip {
+			return nil
+		}
+	}
+
+	switch {
+	case f.fileMatcher != nil:
+		return f.fileMatch(f.scanLines(r, buf, res), res)
+	case whole:
+		f.multilineMatch(buf, finder, res)
+		return nil
+	}
+	if finder, ok := f.matcher.(candidateFinder); ok && r == nil && !f.invert && f.records.mode == RecordLines {
+		f.candidateMatch(buf, finder, res)
+		return nil
+	}
+	return f.lineMatch(f.scanLines(r, buf, res), res)
+}
+
+// lineMatch matches the lines of a file one by one.
+func (f *finder) lineMatch(scan lineScanner, res *fileResult) error {
+	hasMatch := false
+	var collector lineCollector
+	err := scan(f.overlap(f.matcher), func(segment lineSegment) bool {
+		complete, matched := collector.add(f, segment)
+		if !complete {
+			return true
+		}
+		line := collector.line
+		switch {
+		case f.invert && f.onlyFiles:
+			// One match is enough to exclude the file.
+			hasMatch = matched
+			return !matched
+		case matched != f.invert:
+			if len(line.Spans) > 0 {
+				line.Column = line.Spans[0].Start + 1
+			}
+			res.put(line.Number, line)
+			if res.binary {
+				// A match is all that is reported for binary files.
+				return false
+			}
+		}
+		return res.err == nil
+	})
+	if err != nil {
+		return err
+	}
+	if f.invert && f.onlyFiles && !hasMatch {
+		res.selected = true
+	}
+	return nil
+}
+
+// fileMatch feeds the lines of a file to a fileMatcher until the outcome is
+// decided and stores only the file name for the selected files.
+func (f *finder) fileMatch(scan lineScanner, res *fileResult) error {
+	state := f.fileMatcher.newFileState()
+	err := scan(f.overlap(f.fileMatcher), func(segment lineSegment) bool {
+		return !state.feed(segment.text)
+	})
+	if err != nil {
+		return err
+	}
+	if state.matched() != f.invert {
+		res.lines = MakeOnlyFiles()
+		res.selected = true
+	}
+	return nil
+}
+
+func (f *finder) SetGouroutinesLimit(limit int) {
+	f.gouroutinesLimit = limit
+}
+
+// SetOverlapping makes Search report overlapping matches in Line.Spans. By
+// default a match starts only after the end of the previous one.
+func (f *finder) SetOverlapping(overlapping bool) {
+	f.overlapping = overlapping
+}
+
+// SetInvert makes Search select the lines that don't match. If Search is
+// called with onlyFiles, it returns the files without any match instead, and
+// they hold no lines.
+func (f *finder) SetInvert(invert bool) {
+	f.invert = invert
+}
+
+// reset prepares f for a new search.
+func (f *finder) reset(onlyFiles bool) {
+	// A new group, so the error of a previous search isn't returned again.
+	f.errGroup = &errgroup.Group{}
+	f.errGroup.SetLimit(f.gouroutinesLimit)
+	f.mapFiles = MakeMapFiles()
+	f.onlyFiles = onlyFiles
+	if onlyFiles {
+		f.mapMaker = MakeOnlyFiles
+	} else {
+		f.mapMaker = MakeLinesWithText
+	}
+}
+
+// Search searches the file or directory at path in the operating system's
+// file system.
+func (f *finder) Search(path string, onlyFiles bool) (*MapFiles, error) {
+	return f.SearchFS(osFS{}, path, onlyFiles)
+}
+
+// SearchFS searches the file or directory root in fsys, like Search, and
+// stores the results under the names of the files in fsys. Symbolic links
+// are followed, see SetSymlinks, if fsys has a ReadLink method.
+func (f *finder) SearchFS(fsys fs.FS, root string, onlyFiles bool) (*MapFiles, error) {
+	f.reset(onlyFiles)
+	if err := f.newWalkFilter(fsys, root).walk(root, root); err != nil {
+		return nil, err
+	}
+	return f.mapFiles, f.errGroup.Wait()
+}
+
+// SearchReader searches everything read from r, like Search does a file, and
+// stores the results under name. It can be used for standard input or any
+// other stream.
+func (f *finder) SearchReader(name string, r io.Reader) (*MapFiles, error) {
+	f.reset(false)
+	r, _, err := f.convert(r)
+	if err != nil {
+		return nil, err
+	}
+	res := f.newResult()
+	if err := f.matchContent(r, nil, res); err != nil {
+		return nil, err
+	}
+	f.commit(name, res)
+	return f.mapFiles, nil
+}
+
+// SearchBytes searches data, like Search does a file, and stores the results
+// under name.
+func (f *finder) SearchBytes(name string, data []byte) (*MapFiles, error) {
+	f.reset(false)
+	r, converted, err := f.convert(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	if !converted {
+		r = nil
+	}
+	res := f.newResult()
+	if err := f.matchContent(r, data, res); err != nil {
+		return nil, err
+	}
+	f.commit(name, res)
+	return f.mapFiles, nil
+}
+
+// SearchReaderFunc reads r line by line and calls fn with every selected line
+// as soon as the line is read, so it works for streams that never end, like
+// the output of a running program. It returns at the end of r or with the
+// first error returned by r or fn. Multiline search, and binary search with
+// finders whose matches have no bounded length, like RegexpFinder, read the
+// whole stream before calling fn. fn is never called by finders that select
+// whole files, like FileQuery.
+func (f *finder) SearchReaderFunc(r io.Reader, fn func(line *Line) error) error {
+	f.reset(false)
+	r, _, err := f.convert(r)
+	if err != nil {
+		return err
+	}
+	res := f.newResult()
+	res.emit = fn
+	if err := f.matchContent(r, nil, res); err != nil {
+		return err
+	}
+	return res.err
+}
@@ -0,0 +1,75 @@
+//go:build !time
+
+package grep_test
+
+import (
+	"archive/zip"
+	"bytes"
+	"testing"
+	"testing/fstest"
+
+	"github.com/alex123012/go-grep"
+)
+
+func TestSearchFS(t *testing.T) {
+	fsys := fstest.MapFS{
+		"docs/readme.md":     {Data: []byte("kill the process\nthen wait\n")},
+		"docs/nested/man.md": {Data: []byte("nothing here\nkill -9\n")},
+		"other.txt":          {Data: []byte("kill\n")},
+		"docs/binary.bin":    {Data: []byte("\x00\x01kill\n")},
+	}
+	patternSearch := grep.MakeStringFinder("kill")
+	fileMap, err := patternSearch.SearchFS(fsys, "docs", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	result := searchResults(fileMap)
+	if len(result) != 2 || len(result["docs/readme.md"]) != 1 || result["docs/nested/man.md"][2].Text != "kill -9" {
+		t.Fatalf("Expected matches in the two text files of docs, but got %+v", result)
+	}
+
+	fileMap, err = patternSearch.SearchFS(fsys, "other.txt", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, f := fileMap.Get("other.txt"); !f || fileMap.Len() != 1 {
+		t.Fatalf("Expected only other.txt, but got %+v", fileMap.GetStruct())
+	}
+
+	if _, err := patternSearch.SearchFS(fsys, "missing", false); err == nil {
+		t.Fatal("Expected an error for a missing root")
+	}
+}
+
+func TestSearchZipFS(t *testing.T) {
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	for name, content := range map[string]string{
+		"a/first.txt":  "one\ntwo kill\n",
+		"a/second.txt": "three\n",
+	} {
+		file, err := w.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := file.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	r, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fileMap, err := grep.MakeStringFinder("kill").SearchFS(r, ".", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	result := searchResults(fileMap)
+	if len(result) != 1 || result["a/first.txt"][2].Column != 5 {
+		t.Fatalf("Expected a match in a/first.txt, but got %+v", result)
+	}
+}
@@ -0,0 +1,112 @@
+//go:build !time
+
+package grep_test
+
+import (
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/alex123012/go-grep"
+)
+
+const readerText = "kubectl logs\nerror: pod not found\nok\nerror: timeout\n"
+
+func TestSearchReader(t *testing.T) {
+	patternSearch := grep.MakeStringFinder("error")
+	fileMap, err := patternSearch.SearchReader("(standard input)", strings.NewReader(readerText))
+	if err != nil {
+		t.Fatal(err)
+	}
+	v, f := fileMap.Get("(standard input)")
+	if !f || v.(grep.SyncMap).Len() != 2 {
+		t.Fatalf("Expected 2 lines, but got %+v", fileMap.GetStruct())
+	}
+	line, _ := v.(grep.SyncMap).Get(4)
+	expected := &grep.Line{Number: 4, Text: "error: timeout", Column: 1, Spans: []grep.Span{{Start: 0, End: 5}}}
+	if !reflect.DeepEqual(line, expected) {
+		t.Fatalf("Expected %+v, but got %+v", expected, line)
+	}
+
+	bytesMap, err := patternSearch.SearchBytes("(standard input)", []byte(readerText))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(searchResults(bytesMap), searchResults(fileMap)) {
+		t.Fatalf("Expected the same lines from SearchBytes, but got %+v", bytesMap.GetStruct())
+	}
+
+	fileMap, err = patternSearch.SearchReader("empty", strings.NewReader("no match\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v := fileMap.Len(); v != 0 {
+		t.Fatalf("Expected no files, but got %d", v)
+	}
+}
+
+func TestSearchReaderError(t *testing.T) {
+	readErr := errors.New("read failed")
+	r := io.MultiReader(strings.NewReader(readerText), &failingReader{err: readErr})
+	_, err := grep.MakeStringFinder("error").SearchReader("failing", r)
+	if !errors.Is(err, readErr) {
+		t.Fatalf("Expected %v, but got %v", readErr, err)
+	}
+}
+
+func TestSearchReaderFunc(t *testing.T) {
+	r, w := io.Pipe()
+	lines := make(chan *grep.Line)
+	done := make(chan error)
+	go func() {
+		done <- grep.MakeStringFinder("error").SearchReaderFunc(r, func(line *grep.Line) error {
+			lines <- line
+			return nil
+		})
+	}()
+
+	// Every line is reported before the next one is written, as the writer
+	// blocks until the line is read.
+	for i, text := range []string{"error: first\n", "ok\n", "error: second\n"} {
+		if _, err := io.WriteString(w, text); err != nil {
+			t.Fatal(err)
+		}
+		if i == 1 {
+			continue
+		}
+		if line := <-lines; line.Number != i+1 || line.Text != strings.TrimSuffix(text, "\n") {
+			t.Fatalf("Expected line %d, but got %+v", i+1, line)
+		}
+	}
+	w.Close()
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSearchReaderFuncStops(t *testing.T) {
+	stop := errors.New("stop")
+	count := 0
+	err := grep.MakeStringFinder("error").SearchReaderFunc(strings.NewReader(readerText), func(line *grep.Line) error {
+		count++
+		return stop
+	})
+	if !errors.Is(err, stop) || count != 1 {
+		t.Fatalf("Expected to stop after 1 line with %v, but got %d lines and %v", stop, count, err)
+	}
+}
+
+// failingReader returns err from every Read, or fails the test if err is nil.
+type failingReader struct {
+	t   *testing.T
+	err error
+}
+
+func (r *failingReader) Read(p []byte) (int, error) {
+	if r.err == nil {
+		r.t.Fatal("Read past the expected end")
+	}
+	return 0, r.err
+}
@@ -0,0 +1,110 @@
+package grep
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// ErrSymlinkLoop is stored by Search for a symbolic link that leads to itself
+// or to a directory that contains it.
+var ErrSymlinkLoop = errors.New("symbolic link loop")
+
+// maxLinkHops is how many symbolic links in a row are followed before giving
+// up, as operating systems do.
+const maxLinkHops = 40
+
+// SymlinkMode tells which symbolic links met while walking Search follows.
+// A symbolic link given as the root is always followed.
+type SymlinkMode int
+
+const (
+	// SymlinkFiles follows the links to files and skips those to
+	// directories. It is the default.
+	SymlinkFiles SymlinkMode = iota
+	// SymlinkNever skips all links.
+	SymlinkNever
+	// SymlinkAll follows the links to directories too, except those that
+	// lead to a directory containing them, so Search doesn't loop.
+	SymlinkAll
+)
+
+// SetSymlinks sets which symbolic links Search follows. What is reached
+// through a link is stored under the path of the link, and a link that is
+// broken is stored in MapFiles.Errors instead of failing the search.
+func (f *finder) SetSymlinks(mode SymlinkMode) {
+	f.symlinks = mode
+}
+
+// followLink searches what the symbolic link open, named name, leads to.
+func (w *walkFilter) followLink(open, name string) error {
+	if name != w.root && w.symlinks == SymlinkNever {
+		return nil
+	}
+	target, info, err := resolveLink(w.linkFS, open)
+	if err != nil {
+		w.mapFiles.putError(name, err)
+		return nil
+	}
+	if !info.IsDir() {
+		w.match(target, name)
+		return nil
+	}
+	if name != w.root && w.symlinks != SymlinkAll {
+		return nil
+	}
+	return w.walk(target, name)
+}
+
+// resolveLink follows the symbolic link name, and those it leads to, and
+// returns the path of the file at the end and its information.
+func resolveLink(fsys readLinkFS, name string) (string, fs.FileInfo, error) {
+	for i := 0; i < maxLinkHops; i++ {
+		target, err := fsys.ReadLink(name)
+		if err != nil {
+			return "", nil, err
+		}
+		if target = filepath.ToSlash(target); path.IsAbs(target) || filepath.IsAbs(target) {
+			name = target
+		} else {
+			name = path.Join(path.Dir(name), target)
+		}
+
+		info, err := fs.Stat(fsys, name)
+		if err != nil {
+			return "", nil, err
+		}
+		if info.Mode()&fs.ModeSymlink == 0 {
+			return name, info, nil
+		}
+	}
+	return "", nil, fmt.Errorf("%w: %q", ErrSymlinkLoop, name)
+}
+
+// fileKey identifies a directory: by its device and inode or, where they
+// aren't known, by its path.
+type fileKey struct {
+	device, inode uint64
+	name          string
+}
+
+// inLoop records the directory open, walked as name, and reports whether it
+// is already walked above name, which a symbolic link then leads back to.
+func (w *walkFilter) inLoop(open, name string, entry fs.DirEntry) bool {
+	key := fileKey{name: path.Clean(open)}
+	if info, err := entry.Info(); err == nil {
+		if device, inode, ok := fileID(info); ok {
+			key = fileKey{device: device, inode: inode}
+		}
+	}
+	rel := relName(w.root, name)
+	if above, ok := w.visited[key]; ok && (above == "." || strings.HasPrefix(rel, above+"/")) {
+		w.mapFiles.putError(name, fmt.Errorf("%w: %q", ErrSymlinkLoop, name))
+		return true
+	}
+	w.visited[key] = rel
+	return false
+}
@@ -0,0 +1,95 @@
+//go:build !time
+
+package grep_test
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/alex123012/go-grep"
+)
+
+func TestSymlinkModes(t *testing.T) {
+	dir := t.TempDir()
+	writeFileInDir(t, dir, "a.txt", "kill")
+	target := writeFileInDir(t, dir, "sub/b.txt", "kill")
+	for name, link := range map[string]string{
+		"abs_link": target,
+		"rel_link": "a.txt",
+		"chain":    "rel_link",
+		"dirlink":  "sub",
+		"sub/loop": "..",
+		"broken":   "missing",
+		"self":     "self",
+	} {
+		if err := os.Symlink(link, filepath.Join(dir, name)); err != nil {
+			t.Skip(err)
+		}
+	}
+
+	testCases := []struct {
+		mode     grep.SymlinkMode
+		expected []string
+		errors   map[string]error
+	}{
+		{
+			mode:     grep.SymlinkNever,
+			expected: []string{"a.txt", "sub/b.txt"},
+			errors:   map[string]error{},
+		},
+		{
+			mode:     grep.SymlinkFiles,
+			expected: []string{"a.txt", "abs_link", "chain", "rel_link", "sub/b.txt"},
+			errors:   map[string]error{"broken": fs.ErrNotExist, "self": grep.ErrSymlinkLoop},
+		},
+		{
+			mode:     grep.SymlinkAll,
+			expected: []string{"a.txt", "abs_link", "chain", "dirlink/b.txt", "rel_link", "sub/b.txt"},
+			errors: map[string]error{
+				"broken": fs.ErrNotExist, "self": grep.ErrSymlinkLoop,
+				"dirlink/loop": grep.ErrSymlinkLoop, "sub/loop": grep.ErrSymlinkLoop,
+			},
+		},
+	}
+	for _, testCase := range testCases {
+		patternSearch := grep.MakeStringFinder("kill")
+		patternSearch.SetSymlinks(testCase.mode)
+		fileMap, err := patternSearch.Search(dir, true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result := foundFiles(t, fileMap, dir); !reflect.DeepEqual(result, testCase.expected) {
+			t.Errorf("Expected %v with mode %d, but got %v", testCase.expected, testCase.mode, result)
+		}
+
+		var names []string
+		for name, err := range fileMap.Errors() {
+			rel, _ := filepath.Rel(dir, name)
+			names = append(names, rel)
+			if !errors.Is(err, testCase.errors[rel]) {
+				t.Errorf("Expected %v for %s with mode %d, but got %v", testCase.errors[rel], rel, testCase.mode, err)
+			}
+		}
+		if len(names) != len(testCase.errors) {
+			sort.Strings(names)
+			t.Errorf("Expected errors for %v with mode %d, but got %v", testCase.errors, testCase.mode, names)
+		}
+	}
+
+	// A link to a directory given as the root is followed in any mode.
+	patternSearch := grep.MakeStringFinder("kill")
+	patternSearch.SetSymlinks(grep.SymlinkNever)
+	root := filepath.Join(dir, "dirlink")
+	fileMap, err := patternSearch.Search(root, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, f := fileMap.Get(filepath.Join(root, "b.txt")); !f || fileMap.Len() != 1 {
+		t.Errorf("Expected dirlink/b.txt, but got %+v", fileMap.GetStruct())
+	}
+}
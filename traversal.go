@@ -0,0 +1,55 @@
+package grep
+
+import (
+	"io/fs"
+	"path"
+	"strings"
+)
+
+// SetMaxDepth limits how deep Search descends below its root: 1 searches only
+// the files in the root directory. Zero or a negative depth, the default,
+// means no limit.
+func (f *finder) SetMaxDepth(depth int) {
+	f.maxDepth = depth
+}
+
+// SetHidden makes Search walk hidden files and directories, whose names start
+// with a dot. They are skipped by default, except for the root itself.
+func (f *finder) SetHidden(hidden bool) {
+	f.hidden = hidden
+}
+
+// SetOneFileSystem makes Search skip the directories on other devices than
+// its root, like find -xdev, so file systems mounted below the root aren't
+// searched. It has no effect in file systems that don't report the device
+// of files, like those other than the operating system's or on Windows.
+func (f *finder) SetOneFileSystem(one bool) {
+	f.oneFileSystem = one
+}
+
+// depth returns how deep rel, relative to the root, is below it.
+func depth(rel string) int {
+	if rel == "." {
+		return 0
+	}
+	return strings.Count(rel, "/") + 1
+}
+
+// hiddenName reports whether rel, relative to the root, is hidden.
+func hiddenName(rel string) bool {
+	return rel != "." && strings.HasPrefix(path.Base(rel), ".")
+}
+
+// otherDevice reports whether the directory entry is on another device than
+// the root.
+func (w *walkFilter) otherDevice(entry fs.DirEntry) bool {
+	if !w.hasRootDevice {
+		return false
+	}
+	info, err := entry.Info()
+	if err != nil {
+		return false
+	}
+	device, _, ok := fileID(info)
+	return ok && device != w.rootDevice
+}
@@ -0,0 +1,52 @@
+//go:build !time
+
+package grep_test
+
+import (
+	"io/fs"
+	"path/filepath"
+	"reflect"
+	"syscall"
+	"testing"
+	"testing/fstest"
+
+	"github.com/alex123012/go-grep"
+)
+
+func TestOneFileSystem(t *testing.T) {
+	fsys := fstest.MapFS{
+		"root":             {Mode: fs.ModeDir, Sys: &syscall.Stat_t{Dev: 1}},
+		"root/a.txt":       {Data: []byte("kill")},
+		"root/mnt":         {Mode: fs.ModeDir, Sys: &syscall.Stat_t{Dev: 2}},
+		"root/mnt/b.txt":   {Data: []byte("kill")},
+		"root/local":       {Mode: fs.ModeDir, Sys: &syscall.Stat_t{Dev: 1}},
+		"root/local/c.txt": {Data: []byte("kill")},
+	}
+	for _, one := range []bool{false, true} {
+		patternSearch := grep.MakeStringFinder("kill")
+		patternSearch.SetOneFileSystem(one)
+		fileMap, err := patternSearch.SearchFS(fsys, "root", true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected := []string{"root/a.txt", "root/local/c.txt", "root/mnt/b.txt"}
+		if one {
+			expected = expected[:2]
+		}
+		if result := foundFiles(t, fileMap, "."); !reflect.DeepEqual(result, expected) {
+			t.Errorf("Expected %v with one file system %t, but got %v", expected, one, result)
+		}
+	}
+
+	// The devices of the operating system's files are known.
+	patternSearch := grep.MakeStringFinder("kill")
+	patternSearch.SetOneFileSystem(true)
+	fileName := writeTestFile(t, "same.txt", "kill")
+	fileMap, err := patternSearch.Search(filepath.Dir(fileName), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fileMap.Len() != 1 {
+		t.Errorf("Expected the file on the same device, but got %+v", fileMap.GetStruct())
+	}
+}
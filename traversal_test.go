@@ -0,0 +1,49 @@
+//go:build !time
+
+package grep_test
+
+import (
+	"reflect"
+	"testing"
+	"testing/fstest"
+
+	"github.com/alex123012/go-grep"
+)
+
+func TestTraversalLimits(t *testing.T) {
+	fsys := fstest.MapFS{
+		"a.txt":              {Data: []byte("kill")},
+		".env":               {Data: []byte("kill")},
+		".config/app.txt":    {Data: []byte("kill")},
+		"one/b.txt":          {Data: []byte("kill")},
+		"one/.secret":        {Data: []byte("kill")},
+		"one/two/c.txt":      {Data: []byte("kill")},
+		"one/two/three/d.go": {Data: []byte("kill")},
+	}
+	testCases := []struct {
+		root     string
+		maxDepth int
+		hidden   bool
+		expected []string
+	}{
+		{root: ".", expected: []string{"a.txt", "one/b.txt", "one/two/c.txt", "one/two/three/d.go"}},
+		{root: ".", maxDepth: 1, expected: []string{"a.txt"}},
+		{root: ".", maxDepth: 2, hidden: true, expected: []string{".config/app.txt", ".env", "a.txt", "one/.secret", "one/b.txt"}},
+		{root: "one", maxDepth: 2, expected: []string{"one/b.txt", "one/two/c.txt"}},
+		// A hidden root is searched.
+		{root: ".config", expected: []string{".config/app.txt"}},
+		{root: "one/.secret", expected: []string{"one/.secret"}},
+	}
+	for _, testCase := range testCases {
+		patternSearch := grep.MakeStringFinder("kill")
+		patternSearch.SetMaxDepth(testCase.maxDepth)
+		patternSearch.SetHidden(testCase.hidden)
+		fileMap, err := patternSearch.SearchFS(fsys, testCase.root, true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result := foundFiles(t, fileMap, "."); !reflect.DeepEqual(result, testCase.expected) {
+			t.Errorf("Expected %v for %+v, but got %v", testCase.expected, testCase, result)
+		}
+	}
+}
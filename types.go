@@ -0,0 +1,157 @@
+package grep
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// ErrUnknownFileType is returned by SetTypes for a type that isn't
+// registered.
+var ErrUnknownFileType = errors.New("unknown file type")
+
+// FileType tells which files are of a named type, see SetTypes.
+type FileType struct {
+	// Extensions are the file name extensions without the leading dot,
+	// like "go" or "tar.gz".
+	Extensions []string
+	// Names are whole file names, like "Makefile". They may be path.Match
+	// patterns, like "Dockerfile.*".
+	Names []string
+	// Interpreters are the programs named in the shebang line of scripts,
+	// directly or through env, like "python". A version after the name, as
+	// in python3.11, is accepted.
+	Interpreters []string
+}
+
+// fileTypes is the registry of the file types, which AddFileType extends.
+var fileTypes = struct {
+	sync.RWMutex
+	types map[string]FileType
+}{types: map[string]FileType{
+	"c":        {Extensions: []string{"c", "h"}},
+	"cpp":      {Extensions: []string{"cpp", "cc", "cxx", "hpp", "hh", "hxx", "h"}},
+	"css":      {Extensions: []string{"css", "scss", "sass", "less"}},
+	"docker":   {Names: []string{"Dockerfile", "Dockerfile.*", "*.dockerfile", "Containerfile"}},
+	"fasta":    {Extensions: []string{"fasta", "fa", "fna", "ffn", "faa", "frn", "fas", "mpfa"}},
+	"fastq":    {Extensions: []string{"fastq", "fq"}},
+	"go":       {Extensions: []string{"go"}, Names: []string{"go.mod", "go.sum", "go.work"}},
+	"html":     {Extensions: []string{"html", "htm", "xhtml"}},
+	"java":     {Extensions: []string{"java"}},
+	"js":       {Extensions: []string{"js", "mjs", "cjs", "jsx"}, Interpreters: []string{"node"}},
+	"json":     {Extensions: []string{"json", "jsonl", "ndjson"}},
+	"make":     {Extensions: []string{"mk", "mak"}, Names: []string{"Makefile", "makefile", "GNUmakefile"}},
+	"markdown": {Extensions: []string{"md", "markdown", "mdx"}},
+	"perl":     {Extensions: []string{"pl", "pm", "t"}, Interpreters: []string{"perl"}},
+	"proto":    {Extensions: []string{"proto"}},
+	"py":       {Extensions: []string{"py", "pyi", "pyw"}, Interpreters: []string{"python"}},
+	"ruby":     {Extensions: []string{"rb", "gemspec"}, Names: []string{"Gemfile", "Rakefile"}, Interpreters: []string{"ruby"}},
+	"rust":     {Extensions: []string{"rs"}},
+	"sh":       {Extensions: []string{"sh", "bash", "zsh", "ksh"}, Names: []string{".bashrc", ".bash_profile", ".profile", ".zshrc"}, Interpreters: []string{"sh", "bash", "zsh", "ksh", "dash"}},
+	"sql":      {Extensions: []string{"sql"}},
+	"toml":     {Extensions: []string{"toml"}},
+	"ts":       {Extensions: []string{"ts", "tsx", "mts", "cts"}, Interpreters: []string{"deno", "ts-node"}},
+	"xml":      {Extensions: []string{"xml", "xsd", "xsl", "xslt", "svg"}},
+	"yaml":     {Extensions: []string{"yaml", "yml"}},
+}}
+
+// AddFileType registers a file type under name, for every finder. If the type
+// is registered already, the extensions, names and interpreters are added to
+// it.
+func AddFileType(name string, fileType FileType) {
+	fileTypes.Lock()
+	defer fileTypes.Unlock()
+	old := fileTypes.types[name]
+	fileTypes.types[name] = FileType{
+		Extensions:   append(append([]string{}, old.Extensions...), fileType.Extensions...),
+		Names:        append(append([]string{}, old.Names...), fileType.Names...),
+		Interpreters: append(append([]string{}, old.Interpreters...), fileType.Interpreters...),
+	}
+}
+
+// FileTypes returns the names of the registered file types, sorted.
+func FileTypes() []string {
+	fileTypes.RLock()
+	defer fileTypes.RUnlock()
+	names := make([]string, 0, len(fileTypes.types))
+	for name := range fileTypes.types {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// lookupFileTypes returns the registered types of names.
+func lookupFileTypes(names []string) ([]FileType, error) {
+	fileTypes.RLock()
+	defer fileTypes.RUnlock()
+	var types []FileType
+	for _, name := range names {
+		fileType, ok := fileTypes.types[name]
+		if !ok {
+			return nil, fmt.Errorf("%w: %q", ErrUnknownFileType, name)
+		}
+		types = append(types, fileType)
+	}
+	return types, nil
+}
+
+// matchName reports whether a file named base is of the type.
+func (t FileType) matchName(base string) bool {
+	for _, extension := range t.Extensions {
+		if strings.HasSuffix(base, "."+extension) {
+			return true
+		}
+	}
+	for _, name := range t.Names {
+		if ok, _ := path.Match(name, base); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// matchInterpreter reports whether a script run by the program interpreter is
+// of the type.
+func (t FileType) matchInterpreter(interpreter string) bool {
+	for _, name := range t.Interpreters {
+		if interpreter == name || strings.TrimRight(interpreter, "0123456789.") == name {
+			return true
+		}
+	}
+	return false
+}
+
+// shebangLength is how much of the start of a file is read for its shebang
+// line.
+const shebangLength = 256
+
+// interpreter returns the name of the program in the shebang line at the
+// start of data, looking past env and its options, or "" if there is none.
+func interpreter(data []byte) string {
+	if !bytes.HasPrefix(data, []byte("#!")) {
+		return ""
+	}
+	line := data[2:]
+	if i := bytes.IndexByte(line, '\n'); i != -1 {
+		line = line[:i]
+	}
+	fields := strings.Fields(string(line))
+	if len(fields) == 0 {
+		return ""
+	}
+	program := path.Base(fields[0])
+	if program != "env" {
+		return program
+	}
+	for _, field := range fields[1:] {
+		if !strings.HasPrefix(field, "-") && !strings.Contains(field, "=") {
+			return path.Base(field)
+		}
+	}
+	return ""
+}
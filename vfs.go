@@ -0,0 +1,71 @@
+package grep
+
+import (
+	"io/fs"
+	"sort"
+
+	"golang.org/x/tools/godoc/vfs"
+)
+
+// SearchVFS searches the file or directory root in a virtual file system, like
+// Search, and stores the results under the paths of the files in fsys. With a
+// vfs.NameSpace several trees can be searched as one, for example:
+//
+//	ns := vfs.NewNameSpace()
+//	ns.Bind("/", vfs.OS("."), "/", vfs.BindReplace)
+//	ns.Bind("/go", vfs.OS(filepath.Join(runtime.GOROOT(), "src")), "/", vfs.BindReplace)
+//	fileMap, err := finder.SearchVFS(ns, "/", false)
+//
+// Directories are listed as NameSpace.ReadDir does, which for directories
+// bound several times takes Go files only from the first one.
+func (f *finder) SearchVFS(fsys vfs.FileSystem, root string, onlyFiles bool) (*MapFiles, error) {
+	return f.SearchFS(vfsFS{fsys}, root, onlyFiles)
+}
+
+// vfsFS adapts a vfs.FileSystem to fs.FS. Names are the absolute paths of
+// vfs instead of the relative ones of fs.FS.
+type vfsFS struct {
+	fsys vfs.FileSystem
+}
+
+func (v vfsFS) Open(name string) (fs.File, error) {
+	r, err := v.fsys.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	if file, ok := r.(fs.File); ok {
+		// vfs.OS opens *os.File, which may be mapped into memory.
+		return file, nil
+	}
+	return &vfsFile{ReadSeekCloser: r, fsys: v.fsys, name: name}, nil
+}
+
+func (v vfsFS) Stat(name string) (fs.FileInfo, error) {
+	return v.fsys.Lstat(name)
+}
+
+func (v vfsFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	infos, err := v.fsys.ReadDir(name)
+	if err != nil {
+		return nil, err
+	}
+	entries := make([]fs.DirEntry, len(infos))
+	for i, info := range infos {
+		entries[i] = fs.FileInfoToDirEntry(info)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Name() < entries[j].Name()
+	})
+	return entries, nil
+}
+
+// vfsFile is a file opened from a vfs.FileSystem that isn't an fs.File.
+type vfsFile struct {
+	vfs.ReadSeekCloser
+	fsys vfs.FileSystem
+	name string
+}
+
+func (f *vfsFile) Stat() (fs.FileInfo, error) {
+	return f.fsys.Stat(f.name)
+}
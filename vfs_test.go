@@ -0,0 +1,45 @@
+//go:build !time
+
+package grep_test
+
+import (
+	"testing"
+
+	"github.com/alex123012/go-grep"
+	"golang.org/x/tools/godoc/vfs"
+)
+
+func TestSearchVFS(t *testing.T) {
+	repo := t.TempDir()
+	writeFileInDir(t, repo, "main.go", "package main\n\n// TODO: kill the server\n")
+	writeFileInDir(t, repo, "docs/notes.txt", "kill switch\n")
+	goroot := t.TempDir()
+	writeFileInDir(t, goroot, "os/exec.go", "package os\n\nfunc kill() {}\n")
+
+	ns := vfs.NewNameSpace()
+	ns.Bind("/", vfs.OS(repo), "/", vfs.BindReplace)
+	ns.Bind("/go", vfs.OS(goroot), "/", vfs.BindReplace)
+
+	patternSearch := grep.MakeStringFinder("kill")
+	fileMap, err := patternSearch.SearchVFS(ns, "/", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	result := searchResults(fileMap)
+	if len(result) != 3 || result["/main.go"][3].Column != 10 ||
+		result["/docs/notes.txt"][1].Text != "kill switch" || result["/go/os/exec.go"][3].Text != "func kill() {}" {
+		t.Fatalf("Expected matches in 3 files under namespace paths, but got %+v", result)
+	}
+
+	fileMap, err = patternSearch.SearchVFS(ns, "/go", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, f := fileMap.Get("/go/os/exec.go"); !f || fileMap.Len() != 1 {
+		t.Fatalf("Expected only /go/os/exec.go, but got %+v", fileMap.GetStruct())
+	}
+
+	if _, err := patternSearch.SearchVFS(ns, "/missing", false); err == nil {
+		t.Fatal("Expected an error for a missing root")
+	}
+}